@@ -0,0 +1,324 @@
+// Package bot is a headless game client. It logs in, talks the lobby
+// protocol and plays through a Policy without any rendering, so it can run
+// many players from one process (see the loadtest command).
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Session is what /login and /register return. Servers without accounts
+// take a bare ID and no token.
+type Session struct {
+	Token string `json:"token"`
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+}
+
+// Message is a command sent to the server, mirroring the server's Message.
+type Message struct {
+	Type     string  `json:"type"`
+	PlayerID string  `json:"playerId"`
+	Command  string  `json:"command"`
+	AimX     float64 `json:"aimX,omitempty"`
+	AimY     float64 `json:"aimY,omitempty"`
+	Dir      int     `json:"dir,omitempty"`
+
+	Room       string  `json:"room,omitempty"`
+	Name       string  `json:"name,omitempty"`
+	Public     bool    `json:"public,omitempty"`
+	MaxPlayers int     `json:"maxPlayers,omitempty"`
+	Mode       string  `json:"mode,omitempty"`
+	Target     string  `json:"target,omitempty"`
+	Level      int     `json:"level,omitempty"`
+	Ready      bool    `json:"ready,omitempty"`
+	Delay      float64 `json:"delay,omitempty"`
+	Bots       int     `json:"bots,omitempty"`
+	Difficulty string  `json:"difficulty,omitempty"`
+	Rounds     int     `json:"rounds,omitempty"`
+
+	FriendlyFire bool `json:"friendlyFire,omitempty"`
+	FreeForAll   bool `json:"freeForAll,omitempty"`
+}
+
+// Snapshot is the part of the server's GameState bots look at.
+type Snapshot struct {
+	Players  map[string]*Player `json:"players"`
+	Enemies  []*Enemy           `json:"enemies"`
+	Bullets  []*Bullet          `json:"bullets"`
+	Points   int                `json:"points"`
+	Level    int                `json:"level"`
+	Wave     int                `json:"wave"`
+	GameOver bool               `json:"gameOver"`
+}
+
+type Player struct {
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Vx     float64 `json:"vx"`
+	Vy     float64 `json:"vy"`
+	Facing int     `json:"facing"`
+	Lives  int     `json:"lives"`
+	State  string  `json:"state"`
+	Team   int     `json:"team,omitempty"`
+}
+
+type Enemy struct {
+	Kind string  `json:"kind"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+	Vx   float64 `json:"vx"`
+	Dead bool    `json:"dead"`
+	HP   int     `json:"hp"`
+}
+
+type Bullet struct {
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	Vx    float64 `json:"vx"`
+	Vy    float64 `json:"vy"`
+	From  string  `json:"from"`
+	Owner string  `json:"owner,omitempty"`
+}
+
+// RoomInfo is the room a bot is in, as last announced by the server.
+type RoomInfo struct {
+	Code       string       `json:"code"`
+	Host       string       `json:"host"`
+	MaxPlayers int          `json:"maxPlayers"`
+	Started    bool         `json:"started"`
+	Members    []MemberInfo `json:"members,omitempty"`
+}
+
+type MemberInfo struct {
+	ID        string `json:"id"`
+	Ready     bool   `json:"ready"`
+	Spectator bool   `json:"spectator,omitempty"`
+}
+
+// Authenticate logs username in on the server at addr, creating the account
+// when it doesn't exist yet. Servers running without accounts answer 503,
+// in which case the username is used as the player ID.
+func Authenticate(addr, username, password string) (Session, error) {
+	s, status, err := postCredentials(addr, "/login", username, password)
+	if status == http.StatusUnauthorized {
+		s, status, err = postCredentials(addr, "/register", username, password)
+	}
+	if status == http.StatusServiceUnavailable {
+		return Session{ID: username, Name: username}, nil
+	}
+	return s, err
+}
+
+func postCredentials(addr, path, username, password string) (Session, int, error) {
+	var s Session
+	body, _ := json.Marshal(map[string]string{"username": username, "password": password, "name": username})
+	u := url.URL{Scheme: "http", Host: addr, Path: path}
+	resp, err := http.Post(u.String(), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return s, 0, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return s, resp.StatusCode, fmt.Errorf("%s: %s", path, strings.TrimSpace(string(data)))
+	}
+	err = json.Unmarshal(data, &s)
+	return s, resp.StatusCode, err
+}
+
+// Client is one connected bot. Run reads from the server until the
+// connection ends; the other methods may be called from any goroutine.
+type Client struct {
+	ID    string
+	Stats *Stats
+
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+
+	mu        sync.Mutex
+	snapshot  *Snapshot
+	room      *RoomInfo
+	lastError string
+	changed   chan struct{}
+}
+
+// Dial connects to /ws on addr as session s.
+func Dial(addr string, s Session) (*Client, error) {
+	q := url.Values{"id": {s.ID}}
+	if s.Token != "" {
+		q.Set("token", s.Token)
+	}
+	u := url.URL{Scheme: "ws", Host: addr, Path: "/ws", RawQuery: q.Encode()}
+	conn, resp, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("%w (HTTP %d)", err, resp.StatusCode)
+		}
+		return nil, err
+	}
+	c := &Client{ID: s.ID, Stats: newStats(), conn: conn, changed: make(chan struct{}, 1)}
+	conn.SetPongHandler(func(data string) error {
+		var sent int64
+		fmt.Sscan(data, &sent)
+		c.Stats.addRTT(time.Since(time.Unix(0, sent)))
+		return nil
+	})
+	return c, nil
+}
+
+// Run reads messages until the connection closes, pinging the server every
+// pingEvery to measure the round trip. It returns nil after Close.
+func (c *Client) Run(pingEvery time.Duration) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		t := time.NewTicker(pingEvery)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+				c.writeMu.Lock()
+				err := c.conn.WriteControl(websocket.PingMessage, []byte(fmt.Sprint(time.Now().UnixNano())), time.Now().Add(time.Second))
+				c.writeMu.Unlock()
+				if err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		_, msg, err := c.conn.ReadMessage()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) || websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return nil
+			}
+			return err
+		}
+		c.handle(msg, time.Now())
+	}
+}
+
+func (c *Client) handle(msg []byte, now time.Time) {
+	var envelope struct {
+		Type  string    `json:"type"`
+		Room  *RoomInfo `json:"room"`
+		Error string    `json:"error"`
+	}
+	if err := json.Unmarshal(msg, &envelope); err != nil {
+		return
+	}
+	c.Stats.addReceived(len(msg))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch envelope.Type {
+	case "":
+		var s Snapshot
+		if err := json.Unmarshal(msg, &s); err != nil {
+			return
+		}
+		c.Stats.addSnapshot(now)
+		c.snapshot = &s
+	case "room":
+		c.room = envelope.Room
+	case "left":
+		c.room, c.snapshot = nil, nil
+	case "error":
+		c.lastError = envelope.Error
+	default:
+		return
+	}
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Snapshot returns the latest game state, or nil outside a match.
+func (c *Client) Snapshot() *Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.snapshot
+}
+
+// Room returns the room the bot is in, or nil.
+func (c *Client) Room() *RoomInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.room
+}
+
+// LastError returns the last lobby error the server sent.
+func (c *Client) LastError() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastError
+}
+
+// WaitRoom blocks until the bot's room satisfies ok or timeout passes.
+func (c *Client) WaitRoom(ok func(*RoomInfo) bool, timeout time.Duration) (*RoomInfo, error) {
+	deadline := time.After(timeout)
+	for {
+		if r := c.Room(); r != nil && ok(r) {
+			return r, nil
+		}
+		select {
+		case <-c.changed:
+		case <-deadline:
+			if e := c.LastError(); e != "" {
+				return nil, fmt.Errorf("tempo esgotado esperando a sala: %s", e)
+			}
+			return nil, errors.New("tempo esgotado esperando a sala")
+		}
+	}
+}
+
+// Send writes m to the server.
+func (c *Client) Send(m Message) error {
+	m.PlayerID = c.ID
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.Stats.addSent(len(data))
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// Lobby sends a lobby command such as "create", "join", "ready" or "start".
+func (c *Client) Lobby(m Message) error {
+	m.Type = "lobby"
+	return c.Send(m)
+}
+
+// Command sends a gameplay command such as "move", "jump" or "shoot".
+func (c *Client) Command(m Message) error {
+	m.Type = "command"
+	return c.Send(m)
+}
+
+// Close ends the connection; Run returns soon after.
+func (c *Client) Close() error {
+	c.writeMu.Lock()
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	c.writeMu.Unlock()
+	return c.conn.Close()
+}
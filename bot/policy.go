@@ -0,0 +1,149 @@
+package bot
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Policy decides what a bot does. Act is called on every decision tick with
+// the bot's own player and the latest snapshot, and returns the gameplay
+// commands to send. Policies keep per-bot state, so each bot needs its own.
+type Policy interface {
+	Act(me *Player, s *Snapshot) []Message
+}
+
+// Random presses random buttons: it changes direction, jumps, lets go of
+// jump and shoots at random points.
+func Random(rng *rand.Rand) Policy {
+	return &randomPolicy{rng: rng}
+}
+
+type randomPolicy struct {
+	rng *rand.Rand
+	dir int
+}
+
+func (p *randomPolicy) Act(me *Player, s *Snapshot) []Message {
+	var out []Message
+	if p.rng.Float64() < 0.1 {
+		p.dir = p.rng.IntN(3) - 1
+		out = append(out, Message{Command: "move", Dir: p.dir})
+	}
+	switch r := p.rng.Float64(); {
+	case r < 0.15:
+		out = append(out, Message{Command: "jump"})
+	case r < 0.3:
+		out = append(out, Message{Command: "jumpRelease"})
+	}
+	if p.rng.Float64() < 0.3 {
+		out = append(out, Message{Command: "shoot", AimX: p.rng.Float64() * 800, AimY: p.rng.Float64() * 500})
+	}
+	return out
+}
+
+// Scripted plays like a cautious human: it patrols the middle of the
+// screen, jumps over enemy bullets and enemies that get close, and shoots
+// the nearest enemy.
+func Scripted() Policy {
+	return &scriptedPolicy{dir: 1}
+}
+
+const (
+	patrolMin   = 150.0
+	patrolMax   = 650.0
+	dodgeRange  = 120.0
+	jumpHold    = 250 * time.Millisecond
+	playerChest = 20.0
+)
+
+type scriptedPolicy struct {
+	dir      int
+	sentDir  int
+	released time.Time
+	jumping  bool
+}
+
+func (p *scriptedPolicy) Act(me *Player, s *Snapshot) []Message {
+	var out []Message
+	switch {
+	case me.X < patrolMin:
+		p.dir = 1
+	case me.X > patrolMax:
+		p.dir = -1
+	}
+	if p.dir != p.sentDir {
+		p.sentDir = p.dir
+		out = append(out, Message{Command: "move", Dir: p.dir})
+	}
+
+	if p.jumping && time.Now().After(p.released) {
+		p.jumping = false
+		out = append(out, Message{Command: "jumpRelease"})
+	}
+	if !p.jumping && threatened(me, s) {
+		p.jumping = true
+		p.released = time.Now().Add(jumpHold)
+		out = append(out, Message{Command: "jump"})
+	}
+
+	if e := nearestEnemy(me, s); e != nil {
+		out = append(out, Message{Command: "shoot", AimX: e.X, AimY: e.Y - playerChest})
+	}
+	return out
+}
+
+// threatened reports whether an enemy bullet or enemy is about to reach me.
+func threatened(me *Player, s *Snapshot) bool {
+	for _, b := range s.Bullets {
+		if b.From == "enemy" && math.Abs(b.X-me.X) < dodgeRange && (b.X-me.X)*b.Vx < 0 {
+			return true
+		}
+	}
+	for _, e := range s.Enemies {
+		if !e.Dead && math.Abs(e.X-me.X) < dodgeRange/2 {
+			return true
+		}
+	}
+	return false
+}
+
+func nearestEnemy(me *Player, s *Snapshot) *Enemy {
+	var best *Enemy
+	for _, e := range s.Enemies {
+		if e.Dead {
+			continue
+		}
+		if best == nil || math.Abs(e.X-me.X) < math.Abs(best.X-me.X) {
+			best = e
+		}
+	}
+	return best
+}
+
+// Play runs p every tick until stop is closed or a send fails. Ticks
+// without a snapshot, or where the bot has no player, do nothing.
+func (c *Client) Play(p Policy, every time.Duration, stop <-chan struct{}) error {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-t.C:
+		}
+		s := c.Snapshot()
+		if s == nil {
+			continue
+		}
+		me := s.Players[c.ID]
+		if me == nil || me.Lives <= 0 {
+			continue
+		}
+		for _, m := range p.Act(me, s) {
+			if err := c.Command(m); err != nil {
+				return err
+			}
+		}
+	}
+}
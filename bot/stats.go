@@ -0,0 +1,128 @@
+package bot
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Stats measures one connection: ping round trips, how regularly snapshots
+// arrive and how many bytes go each way.
+type Stats struct {
+	mu        sync.Mutex
+	start     time.Time
+	lastSnap  time.Time
+	lastGap   time.Duration
+	rtt       []time.Duration
+	intervals []time.Duration
+	jitter    []time.Duration
+	snapshots int
+	received  int64
+	sent      int64
+}
+
+// Summary is a copy of Stats at one moment.
+type Summary struct {
+	Elapsed   time.Duration
+	Snapshots int
+	Received  int64
+	Sent      int64
+	// RTT holds websocket ping round trips.
+	RTT []time.Duration
+	// Intervals holds the gaps between consecutive snapshots, and Jitter
+	// how much each gap differed from the one before it (as in RFC 3550).
+	Intervals []time.Duration
+	Jitter    []time.Duration
+}
+
+func newStats() *Stats {
+	return &Stats{start: time.Now()}
+}
+
+func (s *Stats) addRTT(d time.Duration) {
+	s.mu.Lock()
+	s.rtt = append(s.rtt, d)
+	s.mu.Unlock()
+}
+
+func (s *Stats) addSnapshot(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots++
+	if !s.lastSnap.IsZero() {
+		gap := now.Sub(s.lastSnap)
+		s.intervals = append(s.intervals, gap)
+		if len(s.intervals) > 1 {
+			s.jitter = append(s.jitter, (gap - s.lastGap).Abs())
+		}
+		s.lastGap = gap
+	}
+	s.lastSnap = now
+}
+
+func (s *Stats) addReceived(n int) {
+	s.mu.Lock()
+	s.received += int64(n)
+	s.mu.Unlock()
+}
+
+func (s *Stats) addSent(n int) {
+	s.mu.Lock()
+	s.sent += int64(n)
+	s.mu.Unlock()
+}
+
+// Summary returns what was measured so far.
+func (s *Stats) Summary() Summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Summary{
+		Elapsed:   time.Since(s.start),
+		Snapshots: s.snapshots,
+		Received:  s.received,
+		Sent:      s.sent,
+		RTT:       append([]time.Duration(nil), s.rtt...),
+		Intervals: append([]time.Duration(nil), s.intervals...),
+		Jitter:    append([]time.Duration(nil), s.jitter...),
+	}
+}
+
+// Since returns what was measured between earlier and s, so setup time in
+// the lobby can be left out of the numbers.
+func (s Summary) Since(earlier Summary) Summary {
+	return Summary{
+		Elapsed:   s.Elapsed - earlier.Elapsed,
+		Snapshots: s.Snapshots - earlier.Snapshots,
+		Received:  s.Received - earlier.Received,
+		Sent:      s.Sent - earlier.Sent,
+		RTT:       s.RTT[len(earlier.RTT):],
+		Intervals: s.Intervals[len(earlier.Intervals):],
+		Jitter:    s.Jitter[len(earlier.Jitter):],
+	}
+}
+
+// Lateness returns how far behind the server's tick schedule each snapshot
+// arrived, given the gaps between snapshots and the tick interval. Delay
+// builds up while gaps run longer than a tick and is worked off by shorter
+// ones, but never goes below zero, so early bursts don't hide later stalls.
+func Lateness(intervals []time.Duration, tick time.Duration) []time.Duration {
+	late := make([]time.Duration, len(intervals))
+	var cur time.Duration
+	for i, gap := range intervals {
+		cur = max(0, cur+gap-tick)
+		late[i] = cur
+	}
+	return late
+}
+
+// Percentile returns the p-th percentile (0 to 100, nearest rank) of ds, or
+// 0 when ds is empty. ds is sorted in place.
+func Percentile(ds []time.Duration, p float64) time.Duration {
+	if len(ds) == 0 {
+		return 0
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	i := int(math.Ceil(p/100*float64(len(ds)))) - 1
+	return ds[max(0, min(i, len(ds)-1))]
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	followZoom  = 2.0
+	cameraSpeed = 0.15
+)
+
+// camera decides which part of the world is shown. The world is drawn at
+// its own size into an offscreen image, and the camera scales and moves that
+// image onto the screen around (X, Y).
+type camera struct {
+	X, Y float64
+	Zoom float64
+}
+
+func newCamera() camera {
+	return camera{X: screenWidth / 2, Y: screenHeight / 2, Zoom: 1}
+}
+
+// moveTowards eases the camera towards (x, y) and keeps the view inside the
+// world.
+func (c *camera) moveTowards(x, y float64) {
+	c.X += (x - c.X) * cameraSpeed
+	c.Y += (y - c.Y) * cameraSpeed
+	c.clamp()
+}
+
+func (c *camera) clamp() {
+	halfW, halfH := screenWidth/(2*c.Zoom), screenHeight/(2*c.Zoom)
+	c.X = max(halfW, min(c.X, screenWidth-halfW))
+	c.Y = max(halfH, min(c.Y, screenHeight-halfH))
+}
+
+func (c camera) apply(op *ebiten.DrawImageOptions) {
+	op.GeoM.Translate(-c.X, -c.Y)
+	op.GeoM.Scale(c.Zoom, c.Zoom)
+	op.GeoM.Translate(screenWidth/2, screenHeight/2)
+}
+
+// updateSpectator handles a spectator's keys: Left/Right pick the player
+// the camera follows, Z zooms in on them and Esc leaves the room.
+func (g *Game) updateSpectator() {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowRight), inpututil.IsKeyJustPressed(ebiten.KeyTab):
+		g.cycleFollowing(1)
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft):
+		g.cycleFollowing(-1)
+	case g.state.Players[g.following] == nil:
+		g.cycleFollowing(0)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyZ) {
+		g.toggleZoom()
+	}
+	g.followCamera()
+}
+
+// cycleFollowing moves the followed player step places along the players
+// in ID order. A step of 0 picks the nearest one when the followed player
+// is gone.
+func (g *Game) cycleFollowing(step int) {
+	ids := make([]string, 0, len(g.state.Players))
+	for id := range g.state.Players {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if len(ids) == 0 {
+		g.following = ""
+		return
+	}
+	i := sort.SearchStrings(ids, g.following)
+	switch {
+	case step > 0:
+		g.following = ids[(i+1)%len(ids)]
+	case step < 0:
+		g.following = ids[(i+len(ids)-1)%len(ids)]
+	default:
+		g.following = ids[min(i, len(ids)-1)]
+	}
+}
+
+func (g *Game) toggleZoom() {
+	if g.camera.Zoom == 1 {
+		g.camera.Zoom = followZoom
+	} else {
+		g.camera.Zoom = 1
+	}
+	g.camera.clamp()
+}
+
+func (g *Game) followCamera() {
+	if p := g.state.Players[g.following]; p != nil {
+		g.camera.moveTowards(p.X, p.Y-playerHeight/2)
+	}
+}
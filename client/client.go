@@ -1,22 +1,25 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"image"
 	"image/color"
+	_ "image/png"
 	"log"
 	"math"
 	"math/rand"
+	"net/http"
 	"net/url"
-	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
-	"github.com/hajimehoshi/ebiten/v2/examples/resources/images"
 )
 
 const (
@@ -28,30 +31,208 @@ const (
 	playerHeight      = 40
 	gravity           = 800.0
 	jumpImpulse       = -350.0
-	periodSun         = 30.0
 	playerBulletSpeed = 500.0
 	shootCooldownTime = 0.5
+	rapidFireCooldown = 0.15
+	itemCollectTime   = 0.35
+	maxCombo          = 5
+	serverAddr        = "localhost:3000"
+	leaderboardSize   = 5
 )
 
-const (
-	frameOX     = 0
-	frameOY     = 32
-	frameWidth  = 32
-	frameHeight = 32
-	frameCount  = 8
-)
+// leaderboardPeriods are the boards the L key cycles through on game over.
+var leaderboardPeriods = []string{"all", "daily", "weekly"}
+
+var periodNames = map[string]string{
+	"all":    "de todos os tempos",
+	"daily":  "das últimas 24 h",
+	"weekly": "dos últimos 7 dias",
+}
 
 var (
-	runnerImage *ebiten.Image
+	projectileImages = map[string]*ebiten.Image{}
+	fruitImages      = map[string]*ebiten.Image{}
+	collectedImage   *ebiten.Image
+	levelImages      = map[string]*ebiten.Image{}
+	enemyImages      = map[string]*ebiten.Image{}
+	characterImages  = map[string]map[string]*ebiten.Image{}
 )
 
+// characters are the playable skins, handed out to players in ID order.
+var characters = []string{"Ninja Frog", "Mask Dude", "Pink Man", "Virtual Guy"}
+
+// characterAnims maps the movement state sent by the server to the sprite
+// sheet that animates it.
+var characterAnims = map[string]string{
+	"idle":       "Idle (32x32).png",
+	"run":        "Run (32x32).png",
+	"jump":       "Jump (32x32).png",
+	"doubleJump": "Double Jump (32x32).png",
+	"fall":       "Fall (32x32).png",
+	"wallSlide":  "Wall Jump (32x32).png",
+}
+
+// levelAssets lists the box, flag and trap sprites, keyed by the name
+// drawBox, drawFlag and drawTrap look them up with.
+var levelAssets = map[string]string{
+	"box1/idle":       "assets/Items/Boxes/Box1/Idle.png",
+	"box1/hit":        "assets/Items/Boxes/Box1/Hit (28x24).png",
+	"box1/break":      "assets/Items/Boxes/Box1/Break.png",
+	"box2/idle":       "assets/Items/Boxes/Box2/Idle.png",
+	"box2/hit":        "assets/Items/Boxes/Box2/Hit (28x24).png",
+	"box2/break":      "assets/Items/Boxes/Box2/Break.png",
+	"box3/idle":       "assets/Items/Boxes/Box3/Idle.png",
+	"box3/hit":        "assets/Items/Boxes/Box3/Hit (28x24).png",
+	"box3/break":      "assets/Items/Boxes/Box3/Break.png",
+	"start/idle":      "assets/Items/Checkpoints/Start/Start (Idle).png",
+	"checkpoint/none": "assets/Items/Checkpoints/Checkpoint/Checkpoint (No Flag).png",
+	"checkpoint/out":  "assets/Items/Checkpoints/Checkpoint/Checkpoint (Flag Out) (64x64).png",
+	"checkpoint/idle": "assets/Items/Checkpoints/Checkpoint/Checkpoint (Flag Idle)(64x64).png",
+	"end/idle":        "assets/Items/Checkpoints/End/End (Idle).png",
+	"end/pressed":     "assets/Items/Checkpoints/End/End (Pressed) (64x64).png",
+	"platform/on":     "assets/Traps/Platforms/Brown On (32x8).png",
+	"falling/on":      "assets/Traps/Falling Platforms/On (32x10).png",
+	"falling/off":     "assets/Traps/Falling Platforms/Off.png",
+	"fire/off":        "assets/Traps/Fire/Off.png",
+	"fire/warn":       "assets/Traps/Fire/Hit (16x32).png",
+	"fire/on":         "assets/Traps/Fire/On (16x32).png",
+	"saw/on":          "assets/Traps/Saw/On (38x38).png",
+	"trampoline/idle": "assets/Traps/Trampoline/Idle.png",
+	"trampoline/jump": "assets/Traps/Trampoline/Jump (28x28).png",
+	"spikes/idle":     "assets/Traps/Spikes/Idle.png",
+	"surfaces":        "assets/Traps/Sand Mud Ice/Sand Mud Ice (16x6).png",
+}
+
+// surfaceTiles is where each surface's 16x6 top strip sits in the sand, mud
+// and ice sheet.
+var surfaceTiles = map[string]image.Point{
+	"sand": {0, 0},
+	"mud":  {64, 0},
+	"ice":  {128, 0},
+}
+
+var fruitNames = map[string]string{
+	"apple":      "Apple",
+	"bananas":    "Bananas",
+	"cherries":   "Cherries",
+	"kiwi":       "Kiwi",
+	"melon":      "Melon",
+	"orange":     "Orange",
+	"pineapple":  "Pineapple",
+	"strawberry": "Strawberry",
+}
+
+var buffNames = map[string]string{
+	"rapidFire":  "Tiro rápido",
+	"tripleShot": "Tiro triplo",
+	"shield":     "Escudo",
+	"doubleJump": "Pulo extra",
+}
+
+// projectileAssets maps projectile types to their sprite. Types without an
+// entry are drawn as plain dots.
+var projectileAssets = map[string]string{
+	"seed":    "assets/Enemies/Plant/Bullet.png",
+	"bark":    "assets/Enemies/Trunk/Bullet.png",
+	"stinger": "assets/Enemies/Bee/Bullet.png",
+}
+
 type Player struct {
-	ID string  `json:"id"`
-	Y  float64 `json:"y"`
-	Vy float64 `json:"vy"`
+	ID           string             `json:"id"`
+	Name         string             `json:"name"`
+	X            float64            `json:"x"`
+	Y            float64            `json:"y"`
+	Vx           float64            `json:"vx"`
+	Vy           float64            `json:"vy"`
+	Facing       int                `json:"facing"`
+	Lives        int                `json:"lives"`
+	Buffs        map[string]float64 `json:"buffs"`
+	Invulnerable float64            `json:"invulnerable"`
+	RespawnX     float64            `json:"respawnX"`
+	State        string             `json:"state"`
+	Stats        PlayerStats        `json:"stats"`
+	Downed       float64            `json:"downed"`
+	Revive       float64            `json:"revive"`
+	Out          bool               `json:"out"`
+	Team         int                `json:"team"`
+	Respawn      float64            `json:"respawn"`
+}
+
+type PlayerStats struct {
+	Score       int     `json:"score"`
+	Kills       int     `json:"kills"`
+	Deaths      int     `json:"deaths"`
+	Shots       int     `json:"shots"`
+	Hits        int     `json:"hits"`
+	DamageTaken int     `json:"damageTaken"`
+	Revives     int     `json:"revives"`
+	Combo       int     `json:"combo"`
+	BestCombo   int     `json:"bestCombo"`
+	ComboTimer  float64 `json:"comboTimer"`
+}
+
+type ScoreLine struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Team int    `json:"team"`
+	PlayerStats
+	Accuracy float64 `json:"accuracy"`
+}
+
+type Scoreboard struct {
+	Type    string      `json:"type"`
+	Points  int         `json:"points"`
+	Level   int         `json:"level"`
+	Players []ScoreLine `json:"players"`
+	Versus  *Versus     `json:"versus"`
+}
+
+type Item struct {
+	Kind      string  `json:"kind"`
+	X         float64 `json:"x"`
+	Y         float64 `json:"y"`
+	Vy        float64 `json:"vy"`
+	Collected bool    `json:"collected"`
+	Timer     float64 `json:"timer"`
+}
+
+type Box struct {
+	Kind   string  `json:"kind"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	HP     int     `json:"hp"`
+	Hit    float64 `json:"hit"`
+	Broken bool    `json:"broken"`
+	Timer  float64 `json:"timer"`
+}
+
+type Flag struct {
+	Kind    string  `json:"kind"`
+	X       float64 `json:"x"`
+	Reached bool    `json:"reached"`
+	Open    bool    `json:"open"`
+	Timer   float64 `json:"timer"`
+}
+
+type Trap struct {
+	Kind    string  `json:"kind"`
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+	W       float64 `json:"w"`
+	H       float64 `json:"h"`
+	State   string  `json:"state"`
+	Timer   float64 `json:"timer"`
+	Surface string  `json:"surface,omitempty"`
+}
+
+type Surface struct {
+	Kind string  `json:"kind"`
+	X1   float64 `json:"x1"`
+	X2   float64 `json:"x2"`
 }
 
 type Enemy struct {
+	Kind       string  `json:"kind"`
 	X          float64 `json:"x"`
 	Y          float64 `json:"y"`
 	Vx         float64 `json:"vx"`
@@ -60,14 +241,21 @@ type Enemy struct {
 	Dead       bool    `json:"dead"`
 	DeathTimer float64 `json:"deathTimer"`
 	WalkPhase  float64 `json:"walkPhase"`
+	HP         int     `json:"hp"`
+	MaxHP      int     `json:"maxHp"`
+	Phase      int     `json:"phase"`
+	Attack     string  `json:"attack,omitempty"`
+	Telegraph  float64 `json:"telegraph,omitempty"`
 }
 
 type Bullet struct {
-	X    float64 `json:"x"`
-	Y    float64 `json:"y"`
-	Vx   float64 `json:"vx"`
-	Vy   float64 `json:"vy"`
-	From string  `json:"from"`
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	Vx    float64 `json:"vx"`
+	Vy    float64 `json:"vy"`
+	From  string  `json:"from"`
+	Type  string  `json:"type"`
+	Owner string  `json:"owner,omitempty"`
 }
 
 type Sun struct {
@@ -78,31 +266,97 @@ type Sun struct {
 
 type GameState struct {
 	Sun      Sun                `json:"sun"`
+	Moon     Moon               `json:"moon"`
 	Players  map[string]*Player `json:"players"`
 	Enemies  []*Enemy           `json:"enemies"`
 	Bullets  []*Bullet          `json:"bullets"`
+	Items    []*Item            `json:"items"`
+	Boxes    []*Box             `json:"boxes"`
+	Flags    []*Flag            `json:"flags"`
+	Traps    []*Trap            `json:"traps"`
+	Surfaces []Surface          `json:"surfaces"`
 	Points   int                `json:"points"`
 	Level    int                `json:"level"`
 	GameOver bool               `json:"gameOver"`
+
+	Wave         int     `json:"wave"`
+	Intermission float64 `json:"intermission"`
+	Seed         uint64  `json:"seed"`
+	BossBonus    int     `json:"bossBonus"`
+	DayTime      float64 `json:"dayTime"`
+	Night        bool    `json:"night"`
+	Weather      Weather `json:"weather"`
+	Mode         string  `json:"mode"`
+	Clock        float64 `json:"clock"`
+	Versus       *Versus `json:"versus"`
 }
 
 type Message struct {
-	Type     string `json:"type"`
-	PlayerID string `json:"playerId"`
-	Command  string `json:"command"`
+	Type     string  `json:"type"`
+	PlayerID string  `json:"playerId"`
+	Command  string  `json:"command"`
+	AimX     float64 `json:"aimX,omitempty"`
+	AimY     float64 `json:"aimY,omitempty"`
+	Dir      int     `json:"dir,omitempty"`
+
+	Room       string  `json:"room,omitempty"`
+	Name       string  `json:"name,omitempty"`
+	Public     bool    `json:"public,omitempty"`
+	MaxPlayers int     `json:"maxPlayers,omitempty"`
+	Mode       string  `json:"mode,omitempty"`
+	Target     string  `json:"target,omitempty"`
+	Level      int     `json:"level,omitempty"`
+	Ready      bool    `json:"ready,omitempty"`
+	Delay      float64 `json:"delay,omitempty"`
+	Bots       int     `json:"bots,omitempty"`
+	Difficulty string  `json:"difficulty,omitempty"`
+	Rounds     int     `json:"rounds,omitempty"`
+
+	FriendlyFire bool `json:"friendlyFire,omitempty"`
+	FreeForAll   bool `json:"freeForAll,omitempty"`
+}
+
+type LeaderboardEntry struct {
+	Mode     string    `json:"mode"`
+	Player   string    `json:"player"`
+	Name     string    `json:"name"`
+	Score    int       `json:"score"`
+	Level    int       `json:"level"`
+	Duration float64   `json:"duration"`
+	Time     time.Time `json:"time"`
+}
+
+type Leaderboard struct {
+	Period  string             `json:"period"`
+	Mode    string             `json:"mode"`
+	Entries []LeaderboardEntry `json:"entries"`
 }
 
 type Game struct {
 	wsConn        *websocket.Conn
+	login         *loginScreen
+	lobby         *lobbyScreen
+	replay        *replayViewer
+	room          *RoomInfo
+	world         *ebiten.Image
+	camera        camera
+	following     string
 	state         GameState
+	scoreboard    *Scoreboard
+	leaderboard   *Leaderboard
+	periodIndex   int
 	localPlayerID string
 
 	lastSpace bool
+	lastL     bool
 	lastZ     bool
+	lastMouse bool
+	lastDir   int
 
 	shootCooldown float64
 	time          float64
 	count         int
+	particles     []particle
 }
 
 func NewGame() *Game {
@@ -116,23 +370,35 @@ func NewGame() *Game {
 			Level:    1,
 			GameOver: false,
 		},
+		lobby:         newLobbyScreen(),
+		camera:        newCamera(),
 		shootCooldown: shootCooldownTime,
 		localPlayerID: "player1",
 		time:          0,
 	}
 }
 
-func (g *Game) connectWebSocket() {
+// dialServer opens the game connection for session s. The session token is
+// sent when the server issued one; servers without accounts accept a bare
+// player ID instead.
+func dialServer(s session) (*websocket.Conn, error) {
+	q := url.Values{"id": {s.ID}}
+	if s.Token != "" {
+		q = url.Values{"token": {s.Token}}
+	}
 	u := url.URL{
 		Scheme:   "ws",
-		Host:     "localhost:3000",
+		Host:     serverAddr,
 		Path:     "/ws",
-		RawQuery: "id=" + g.localPlayerID,
+		RawQuery: q.Encode(),
 	}
 	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
-	if err != nil {
-		log.Fatal("Erro na conexão WebSocket:", err)
-	}
+	return conn, err
+}
+
+// attach starts playing over conn as the player of session s.
+func (g *Game) attach(s session, conn *websocket.Conn) {
+	g.localPlayerID = s.ID
 	g.wsConn = conn
 	go g.readMessages()
 }
@@ -144,6 +410,27 @@ func (g *Game) readMessages() {
 			log.Println("Erro ao ler mensagem do servidor:", err)
 			return
 		}
+		var envelope struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(msg, &envelope); err != nil {
+			log.Println("Erro ao decodificar mensagem:", err)
+			continue
+		}
+		if g.handleLobbyMessage(envelope.Type, msg) {
+			continue
+		}
+		if envelope.Type == "scoreboard" {
+			var board Scoreboard
+			if err := json.Unmarshal(msg, &board); err != nil {
+				log.Println("Erro ao decodificar placar:", err)
+				continue
+			}
+			g.scoreboard = &board
+			go g.fetchLeaderboard(leaderboardPeriods[g.periodIndex], g.state.Mode)
+			continue
+		}
+
 		var newState GameState
 		if err := json.Unmarshal(msg, &newState); err != nil {
 			log.Println("Erro ao decodificar estado:", err)
@@ -151,9 +438,47 @@ func (g *Game) readMessages() {
 		}
 
 		g.state = newState
+		if !newState.GameOver {
+			g.scoreboard = nil
+			g.leaderboard = nil
+		}
 	}
 }
 
+// fetchLeaderboard downloads the top runs of period in mode from the
+// server's leaderboard endpoint. Versus has no leaderboard.
+func (g *Game) fetchLeaderboard(period, mode string) {
+	if mode == "versus" {
+		return
+	}
+	query := url.Values{"period": {period}, "n": {strconv.Itoa(leaderboardSize)}}
+	if mode != "" {
+		query.Set("mode", mode)
+	}
+	u := url.URL{
+		Scheme:   "http",
+		Host:     serverAddr,
+		Path:     "/leaderboard",
+		RawQuery: query.Encode(),
+	}
+	resp, err := http.Get(u.String())
+	if err != nil {
+		log.Println("Erro ao buscar recordes:", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Println("Erro ao buscar recordes:", resp.Status)
+		return
+	}
+	var board Leaderboard
+	if err := json.NewDecoder(resp.Body).Decode(&board); err != nil {
+		log.Println("Erro ao decodificar recordes:", err)
+		return
+	}
+	g.leaderboard = &board
+}
+
 func (g *Game) sendMessage(m Message) {
 	if g.wsConn == nil {
 		return
@@ -169,6 +494,24 @@ func (g *Game) sendMessage(m Message) {
 }
 
 func (g *Game) updateInput() {
+	dir := 0
+	if ebiten.IsKeyPressed(ebiten.KeyArrowLeft) || ebiten.IsKeyPressed(ebiten.KeyA) {
+		dir--
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyArrowRight) || ebiten.IsKeyPressed(ebiten.KeyD) {
+		dir++
+	}
+	if dir != g.lastDir {
+		m := Message{
+			Type:     "command",
+			PlayerID: g.localPlayerID,
+			Command:  "move",
+			Dir:      dir,
+		}
+		g.sendMessage(m)
+	}
+	g.lastDir = dir
+
 	curSpace := ebiten.IsKeyPressed(ebiten.KeySpace)
 	if curSpace && !g.lastSpace {
 		m := Message{
@@ -178,6 +521,13 @@ func (g *Game) updateInput() {
 		}
 		g.sendMessage(m)
 	}
+	if !curSpace && g.lastSpace {
+		g.sendMessage(Message{
+			Type:     "command",
+			PlayerID: g.localPlayerID,
+			Command:  "jumpRelease",
+		})
+	}
 	g.lastSpace = curSpace
 
 	curZ := ebiten.IsKeyPressed(ebiten.KeyZ)
@@ -188,13 +538,35 @@ func (g *Game) updateInput() {
 			Command:  "shoot",
 		}
 		g.sendMessage(m)
-		g.shootCooldown = shootCooldownTime
+		g.shootCooldown = g.cooldownTime()
 	}
 	g.lastZ = curZ
+
+	curMouse := ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft)
+	if curMouse && !g.lastMouse && g.shootCooldown <= 0 {
+		mx, my := ebiten.CursorPosition()
+		m := Message{
+			Type:     "command",
+			PlayerID: g.localPlayerID,
+			Command:  "shoot",
+			AimX:     float64(mx),
+			AimY:     float64(my),
+		}
+		g.sendMessage(m)
+		g.shootCooldown = g.cooldownTime()
+	}
+	g.lastMouse = curMouse
 	if g.shootCooldown > 0 {
 		g.shootCooldown -= 1.0 / 60.0
 	}
 
+	curL := ebiten.IsKeyPressed(ebiten.KeyL)
+	if g.state.GameOver && curL && !g.lastL {
+		g.periodIndex = (g.periodIndex + 1) % len(leaderboardPeriods)
+		go g.fetchLeaderboard(leaderboardPeriods[g.periodIndex], g.state.Mode)
+	}
+	g.lastL = curL
+
 	if g.state.GameOver && ebiten.IsKeyPressed(ebiten.KeyR) {
 		m := Message{
 			Type:     "command",
@@ -205,6 +577,15 @@ func (g *Game) updateInput() {
 	}
 }
 
+// cooldownTime mirrors the server's fire rate so that rapid fire is not
+// throttled on the client.
+func (g *Game) cooldownTime() float64 {
+	if p, ok := g.state.Players[g.localPlayerID]; ok && p.Buffs["rapidFire"] > 0 {
+		return rapidFireCooldown
+	}
+	return shootCooldownTime
+}
+
 func drawCircle(screen *ebiten.Image, cx, cy, r float64, clr color.Color) {
 	const segments = 40
 	theta := 2 * math.Pi / segments
@@ -231,111 +612,587 @@ func drawFilledCircle(screen *ebiten.Image, cx, cy, r float64, clr color.Color)
 }
 
 func (g *Game) drawPlayer(screen *ebiten.Image, p *Player) {
+	if p.Out || p.Invulnerable > 0 && (g.count/4)%2 == 0 {
+		return
+	}
+	if p.Downed > 0 {
+		drawReviveMarker(screen, p)
+	}
 	op := &ebiten.DrawImageOptions{}
+	if p.Lives <= 0 {
+		op.ColorScale.ScaleAlpha(0.35)
+	}
+	tintTeam(op, p.Team)
 
-	scale := 4.0
+	const frameSize, scale = 32, 1.5
+	op.GeoM.Translate(-frameSize/2, -frameSize)
+	if p.Facing < 0 {
+		op.GeoM.Scale(-1, 1)
+	}
 	op.GeoM.Scale(scale, scale)
-	op.GeoM.Translate(-float64(frameWidth)*scale/2, -float64(frameHeight)*scale/2)
-	op.GeoM.Translate(playerX, p.Y)
+	op.GeoM.Translate(p.X, p.Y)
+
+	anims := characterImages[g.characterFor(p.ID)]
+	img := anims[p.State]
+	if img == nil {
+		img = anims["idle"]
+	}
+	drawSpriteFrame(screen, img, (g.count/3)%max(img.Bounds().Dx()/frameSize, 1), frameSize, frameSize, 0, 0, op)
+
+	if p.Buffs["shield"] > 0 {
+		drawCircle(screen, p.X, p.Y-float64(playerHeight)/2, 36, color.RGBA{R: 80, G: 200, B: 255, A: 255})
+	}
+	drawTeamMarker(screen, p)
+}
 
-	i := (g.count / 5) % frameCount
-	sx, sy := frameOX+i*frameWidth, frameOY
+// characterFor picks a skin by the player's position among the sorted IDs so
+// every client shows the same character for the same player.
+func (g *Game) characterFor(id string) string {
+	i := 0
+	for other := range g.state.Players {
+		if other < id {
+			i++
+		}
+	}
+	return characters[i%len(characters)]
+}
 
-	screen.DrawImage(runnerImage.SubImage(image.Rect(sx, sy, sx+frameWidth, sy+frameHeight)).(*ebiten.Image), op)
+func (g *Game) drawItem(screen *ebiten.Image, it *Item) {
+	const frameSize = 32
+	img := fruitImages[it.Kind]
+	i := (g.count / 3) % 17
+	if it.Collected {
+		img = collectedImage
+		i = min(int(it.Timer/itemCollectTime*6), 5)
+	}
+	if img == nil {
+		return
+	}
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(-frameSize/2, -frameSize/2)
+	op.GeoM.Translate(it.X, it.Y)
+	screen.DrawImage(img.SubImage(image.Rect(i*frameSize, 0, (i+1)*frameSize, frameSize)).(*ebiten.Image), op)
 }
 
-func drawEnemy(screen *ebiten.Image, e *Enemy) {
+func drawSpriteFrame(screen *ebiten.Image, img *ebiten.Image, frame, w, h int, x, y float64, op *ebiten.DrawImageOptions) {
+	if img == nil {
+		return
+	}
+	frames := max(img.Bounds().Dx()/w, 1)
+	frame = min(frame, frames-1)
+	op.GeoM.Translate(x, y)
+	screen.DrawImage(img.SubImage(image.Rect(frame*w, 0, (frame+1)*w, h)).(*ebiten.Image), op)
+}
+
+func (g *Game) drawBox(screen *ebiten.Image, b *Box) {
+	const w, h = 28, 24
+	op := &ebiten.DrawImageOptions{}
+	switch {
+	case b.Broken:
+		drawSpriteFrame(screen, levelImages[b.Kind+"/break"], int(b.Timer/0.1), w, h, b.X-w/2, b.Y, op)
+	case b.Hit > 0:
+		drawSpriteFrame(screen, levelImages[b.Kind+"/hit"], (g.count/4)%4, w, h, b.X-w/2, b.Y, op)
+	default:
+		drawSpriteFrame(screen, levelImages[b.Kind+"/idle"], 0, w, h, b.X-w/2, b.Y, op)
+	}
+}
+
+// drawSurfaceStrip tiles the 16x6 strip of a surface kind from x1 to x2 with
+// its top edge at y.
+func drawSurfaceStrip(screen *ebiten.Image, kind string, x1, x2, y float64) {
+	const w, h = 16, 6
+	img := levelImages["surfaces"]
+	origin, ok := surfaceTiles[kind]
+	if img == nil || !ok {
+		return
+	}
+	tile := img.SubImage(image.Rect(origin.X, origin.Y, origin.X+w, origin.Y+h)).(*ebiten.Image)
+	for x := x1; x < x2; x += w {
+		op := &ebiten.DrawImageOptions{}
+		if x+w > x2 {
+			tile = img.SubImage(image.Rect(origin.X, origin.Y, origin.X+int(x2-x), origin.Y+h)).(*ebiten.Image)
+		}
+		op.GeoM.Translate(x, y)
+		screen.DrawImage(tile, op)
+	}
+}
+
+func (g *Game) drawTrap(screen *ebiten.Image, t *Trap) {
+	op := &ebiten.DrawImageOptions{}
+	left := t.X - t.W/2
+	switch t.Kind {
+	case "platform":
+		const w = 32
+		img := levelImages["platform/on"]
+		for x := left; x < left+t.W; x += w {
+			drawSpriteFrame(screen, img, (g.count/4)%8, w, 8, x, t.Y, &ebiten.DrawImageOptions{})
+		}
+		if t.Surface != "" {
+			drawSurfaceStrip(screen, t.Surface, left, left+t.W, t.Y-2)
+		}
+	case "falling":
+		switch t.State {
+		case "gone":
+			return
+		case "shaking":
+			op.GeoM.Translate(float64(g.count%3-1), 0)
+			drawSpriteFrame(screen, levelImages["falling/off"], 0, 32, 10, left, t.Y, op)
+		case "falling":
+			drawSpriteFrame(screen, levelImages["falling/off"], 0, 32, 10, left, t.Y, op)
+		default:
+			drawSpriteFrame(screen, levelImages["falling/on"], (g.count/5)%4, 32, 10, left, t.Y, op)
+		}
+	case "fire":
+		switch t.State {
+		case "on":
+			drawSpriteFrame(screen, levelImages["fire/on"], (g.count/4)%3, 16, 32, left, t.Y, op)
+		case "warn":
+			drawSpriteFrame(screen, levelImages["fire/warn"], (g.count/4)%4, 16, 32, left, t.Y, op)
+		default:
+			drawSpriteFrame(screen, levelImages["fire/off"], 0, 16, 32, left, t.Y, op)
+		}
+	case "saw":
+		drawSpriteFrame(screen, levelImages["saw/on"], (g.count/2)%8, 38, 38, left, t.Y-t.H/2, op)
+	case "trampoline":
+		if t.State == "jump" {
+			drawSpriteFrame(screen, levelImages["trampoline/jump"], int(t.Timer/0.05), 28, 28, left, t.Y, op)
+		} else {
+			drawSpriteFrame(screen, levelImages["trampoline/idle"], 0, 28, 28, left, t.Y, op)
+		}
+	case "spikes":
+		for x := left; x < left+t.W; x += 16 {
+			drawSpriteFrame(screen, levelImages["spikes/idle"], 0, 16, 16, x, t.Y, &ebiten.DrawImageOptions{})
+		}
+	}
+}
+
+func (g *Game) drawFlag(screen *ebiten.Image, f *Flag) {
+	const size = 64
+	op := &ebiten.DrawImageOptions{}
+	x, y := f.X-size/2, float64(groundY)-size
+	switch f.Kind {
+	case "start":
+		drawSpriteFrame(screen, levelImages["start/idle"], 0, size, size, x, y, op)
+	case "checkpoint":
+		outFrames := 26
+		switch {
+		case !f.Reached:
+			drawSpriteFrame(screen, levelImages["checkpoint/none"], 0, size, size, x, y, op)
+		case int(f.Timer/0.05) < outFrames:
+			drawSpriteFrame(screen, levelImages["checkpoint/out"], int(f.Timer/0.05), size, size, x, y, op)
+		default:
+			drawSpriteFrame(screen, levelImages["checkpoint/idle"], (g.count/5)%10, size, size, x, y, op)
+		}
+	case "end":
+		if !f.Open {
+			op.ColorScale.ScaleAlpha(0.4)
+		}
+		if f.Reached {
+			drawSpriteFrame(screen, levelImages["end/pressed"], int(f.Timer/0.075), size, size, x, y, op)
+		} else {
+			drawSpriteFrame(screen, levelImages["end/idle"], 0, size, size, x, y, op)
+		}
+	}
+}
+
+// drawPlayersHUD lists every player with their score, lives, combo and
+// active buffs.
+func (g *Game) drawPlayersHUD(screen *ebiten.Image) {
+	ids := make([]string, 0, len(g.state.Players))
+	for id := range g.state.Players {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for row, id := range ids {
+		p := g.state.Players[id]
+		line := fmt.Sprintf("%s  Pontos: %d  Vidas: %d", p.Name, p.Stats.Score, p.Lives)
+		switch {
+		case p.Downed > 0:
+			line += fmt.Sprintf("  CAÍDO %ds", int(math.Ceil(p.Downed)))
+		case p.Out:
+			line += "  FORA"
+		case p.Respawn > 0:
+			line += fmt.Sprintf("  VOLTA EM %ds", int(math.Ceil(p.Respawn)))
+		}
+		if p.Stats.Combo > 1 {
+			line += fmt.Sprintf("  Combo x%d", min(p.Stats.Combo, maxCombo))
+		}
+		if id == g.localPlayerID {
+			line = "> " + line
+		}
+		buffs := make([]string, 0, len(p.Buffs))
+		for buff := range p.Buffs {
+			buffs = append(buffs, buff)
+		}
+		sort.Strings(buffs)
+		for _, buff := range buffs {
+			line += fmt.Sprintf("  %s %ds", buffNames[buff], int(math.Ceil(p.Buffs[buff])))
+		}
+		ebitenutil.DebugPrintAt(screen, line, 10, 20+row*16)
+	}
+}
+
+func (g *Game) drawEnemy(screen *ebiten.Image, e *Enemy) {
+	if g.drawEnemySprite(screen, e) {
+		return
+	}
 	clr := color.RGBA{R: 200, G: 0, B: 0, A: 255}
-	headRadius := 15.0
+	size := 1.0
+	switch e.Kind {
+	case "runner":
+		clr = color.RGBA{R: 230, G: 120, B: 0, A: 255}
+	case "flyer":
+		clr = color.RGBA{R: 160, G: 0, B: 200, A: 255}
+	case "boss":
+		clr = color.RGBA{R: 120, G: 0, B: 0, A: 255}
+		size = 2.5
+	}
+	if e.Attack != "" && (g.count/4)%2 == 0 {
+		clr = color.RGBA{R: 255, G: 220, B: 0, A: 255}
+	}
+	headRadius := 15.0 * size
+	legLength := 20.0 * size
+	hipY := e.Y + 20 - legLength
 	headX := e.X
-	headY := e.Y - float64(playerHeight) - headRadius
+	headY := hipY - float64(playerHeight)*size - headRadius
 	drawCircle(screen, headX, headY, headRadius, clr)
 	if e.Dead {
 		offset := headRadius * 0.7
 		ebitenutil.DrawLine(screen, headX-offset, headY-offset, headX+offset, headY+offset, color.White)
 		ebitenutil.DrawLine(screen, headX+offset, headY-offset, headX-offset, headY+offset, color.White)
 	}
-	ebitenutil.DrawLine(screen, headX, headY+headRadius, headX, e.Y, clr)
+	ebitenutil.DrawLine(screen, headX, headY+headRadius, headX, hipY, clr)
 	shoulderY := headY + headRadius*2
-	armLength := 20.0
+	armLength := 20.0 * size
 	ebitenutil.DrawLine(screen, headX, shoulderY, headX-armLength, shoulderY, clr)
 	ebitenutil.DrawLine(screen, headX, shoulderY, headX+armLength, shoulderY, clr)
-	legLength := 20.0
 	if !e.Dead {
-		legOffset := 5.0 * math.Sin(e.WalkPhase)
-		ebitenutil.DrawLine(screen, headX, e.Y, headX-10+legOffset, e.Y+legLength, clr)
-		ebitenutil.DrawLine(screen, headX, e.Y, headX+10-legOffset, e.Y+legLength, clr)
+		legOffset := 5.0 * size * math.Sin(e.WalkPhase)
+		ebitenutil.DrawLine(screen, headX, hipY, headX-10*size+legOffset, hipY+legLength, clr)
+		ebitenutil.DrawLine(screen, headX, hipY, headX+10*size-legOffset, hipY+legLength, clr)
 	} else {
-		ebitenutil.DrawLine(screen, headX, e.Y, headX-15, e.Y+legLength, clr)
-		ebitenutil.DrawLine(screen, headX, e.Y, headX+15, e.Y+legLength, clr)
+		ebitenutil.DrawLine(screen, headX, hipY, headX-15*size, hipY+legLength, clr)
+		ebitenutil.DrawLine(screen, headX, hipY, headX+15*size, hipY+legLength, clr)
+	}
+
+	if e.Attack != "" && !e.Dead {
+		g.drawTelegraph(screen, e, headX, headY-headRadius)
+	}
+}
+
+// drawTelegraph warns about the attack a boss is winding up. Aimed attacks
+// point at the player the server targets, the one with the lowest ID among
+// those still standing.
+func (g *Game) drawTelegraph(screen *ebiten.Image, e *Enemy, x, y float64) {
+	warn := color.RGBA{R: 255, G: 220, B: 0, A: 160}
+	ebitenutil.DebugPrintAt(screen, "!", int(x)-3, int(y)-20)
+	originY := e.Y - float64(playerHeight)
+	switch e.Attack {
+	case "aimed", "burst":
+		var target *Player
+		for id, p := range g.state.Players {
+			if p.Lives <= 0 || p.Downed > 0 || p.Out {
+				continue
+			}
+			if target == nil || id < target.ID {
+				target = p
+			}
+		}
+		if target != nil {
+			ebitenutil.DrawLine(screen, e.X, originY, target.X, target.Y-float64(playerHeight)/2, warn)
+		}
+	case "spread":
+		for i := -2; i <= 2; i++ {
+			angle := math.Pi + float64(i)*0.22
+			ebitenutil.DrawLine(screen, e.X, originY, e.X+math.Cos(angle)*120, originY+math.Sin(angle)*120, warn)
+		}
+	case "summon":
+		drawCircle(screen, e.X-40, e.Y, 25*(1-e.Telegraph), warn)
+	}
+}
+
+func drawBullet(screen *ebiten.Image, b *Bullet) {
+	if img, ok := projectileImages[b.Type]; ok {
+		w, h := img.Bounds().Dx(), img.Bounds().Dy()
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Translate(-float64(w)/2, -float64(h)/2)
+		if b.Type != "seed" {
+			op.GeoM.Rotate(math.Atan2(b.Vy, b.Vx) + math.Pi)
+		}
+		op.GeoM.Scale(1.5, 1.5)
+		op.GeoM.Translate(b.X, b.Y)
+		screen.DrawImage(img, op)
+		return
+	}
+	var clr color.Color = color.White
+	if b.From == "enemy" {
+		clr = color.Gray16{0x8888}
 	}
+	ebitenutil.DrawCircle(screen, b.X, b.Y, 2, clr)
+}
+
+func drawBossBar(screen *ebiten.Image, e *Enemy) {
+	const barWidth = 400.0
+	x := float64(screenWidth)/2 - barWidth/2
+	y := 20.0
+	frac := float64(e.HP) / math.Max(float64(e.MaxHP), 1)
+	ebitenutil.DrawRect(screen, x-2, y-2, barWidth+4, 14, color.RGBA{R: 20, G: 20, B: 20, A: 255})
+	ebitenutil.DrawRect(screen, x, y, barWidth*frac, 10, color.RGBA{R: 200, G: 30, B: 30, A: 255})
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Chefe - Fase %d", e.Phase+1), int(x), int(y)+12)
 }
 
 func (g *Game) Update() error {
+	g.updateWeather(1.0 / 60.0)
+	if g.replay != nil {
+		return g.updateReplay()
+	}
 	g.count++
 	dt := 1.0 / 60.0
 	g.time += dt
+	if g.login != nil {
+		g.updateLogin()
+		return nil
+	}
+	if !g.inMatch() {
+		g.updateLobby()
+		return nil
+	}
+	g.updateMatchControls()
+	if g.spectating() {
+		g.updateSpectator()
+		return nil
+	}
+	if p := g.state.Players[g.localPlayerID]; p != nil && p.Out {
+		g.updateSpectator()
+		return nil
+	}
+	// Players who bled out follow a teammate like a spectator until the
+	// next level; everyone else sees the whole level.
+	g.camera = newCamera()
 	g.updateInput()
 	return nil
 }
 
 func (g *Game) Draw(screen *ebiten.Image) {
-	skyColor := color.RGBA{R: 30, G: 30, B: 80, A: 255}
-	screen.Fill(skyColor)
+	if g.login != nil {
+		g.drawLogin(screen)
+		return
+	}
+	if !g.inMatch() {
+		g.drawLobby(screen)
+		return
+	}
+	if g.world == nil {
+		g.world = ebiten.NewImage(screenWidth, screenHeight)
+	}
+	g.drawWorld(g.world)
+	op := &ebiten.DrawImageOptions{}
+	g.camera.apply(op)
+	screen.DrawImage(g.world, op)
+
+	for _, e := range g.state.Enemies {
+		if e.Kind == "boss" && !e.Dead {
+			drawBossBar(screen, e)
+		}
+	}
 
-	drawFilledCircle(screen, g.state.Sun.X, g.state.Sun.Y, 40, g.state.Sun.Color)
+	scoreStr := fmt.Sprintf("Pontos: %d  Nível: %d  Onda: %d", g.state.Points, g.state.Level, g.state.Wave)
+	if g.state.Mode == "timeattack" {
+		secs := int(math.Ceil(g.state.Clock))
+		scoreStr += fmt.Sprintf("  Tempo: %d:%02d", secs/60, secs%60)
+	}
+	if name, ok := weatherNames[g.state.Weather.Kind]; ok {
+		scoreStr += "  Clima: " + name
+	}
+	ebitenutil.DebugPrintAt(screen, scoreStr, screenWidth/2-100, 0)
+	g.drawPlayersHUD(screen)
+	g.drawDownedHUD(screen)
+	g.drawVersusHUD(screen)
+	if g.spectating() {
+		g.drawSpectatorHUD(screen)
+	}
+	if g.replay != nil {
+		g.drawReplayHUD(screen)
+	}
 
-	ebitenutil.DrawRect(screen, 0, float64(groundY), float64(screenWidth), float64(screenHeight)-float64(groundY), color.RGBA{R: 80, G: 50, B: 20, A: 255})
+	if g.state.Intermission > 0 {
+		intermissionStr := fmt.Sprintf("Nível %d concluído! Próximo nível em %d s", g.state.Level, int(math.Ceil(g.state.Intermission)))
+		ebitenutil.DebugPrintAt(screen, intermissionStr, screenWidth/2-120, screenHeight/2-40)
+		if g.state.BossBonus > 0 {
+			bonusStr := fmt.Sprintf("Chefe derrotado! Bônus: +%d", g.state.BossBonus)
+			ebitenutil.DebugPrintAt(screen, bonusStr, screenWidth/2-120, screenHeight/2-20)
+		}
+	}
 
-	for id, p := range g.state.Players {
-		if id == g.localPlayerID {
-			g.drawPlayer(screen, p)
-		} else {
-			g.drawPlayer(screen, p)
-			ebitenutil.DebugPrintAt(screen, id, 10, 20)
+	if g.state.GameOver {
+		gameOverStr := "Você Perdeu! Pressione R para Recomeçar"
+		if g.state.Mode == "timeattack" {
+			gameOverStr = fmt.Sprintf("Tempo esgotado! %d pontos. Pressione R para Recomeçar", g.state.Points)
+		}
+		if v := g.state.Versus; v != nil && v.Winner > 0 {
+			gameOverStr = fmt.Sprintf("%s venceu a partida! Pressione R para jogar de novo", g.sideName(v.Winner))
 		}
+		if g.replay != nil {
+			gameOverStr = "Fim da partida"
+		}
+		ebitenutil.DebugPrintAt(screen, gameOverStr, screenWidth/2-100, screenHeight/2)
+		if g.scoreboard != nil {
+			drawScoreboard(screen, g.scoreboard, screenWidth/2-220, screenHeight/2+30)
+		}
+		if g.leaderboard != nil {
+			drawLeaderboard(screen, g.leaderboard, screenWidth/2-220, 60)
+		}
+	}
+}
+
+// drawWorld draws the level and everything in it at world coordinates.
+func (g *Game) drawWorld(world *ebiten.Image) {
+	g.drawSky(world)
+
+	ebitenutil.DrawRect(world, 0, float64(groundY), float64(screenWidth), float64(screenHeight)-float64(groundY), color.RGBA{R: 80, G: 50, B: 20, A: 255})
+	for _, z := range g.state.Surfaces {
+		drawSurfaceStrip(world, z.Kind, z.X1, z.X2, float64(groundY))
+	}
+
+	for _, t := range g.state.Traps {
+		g.drawTrap(world, t)
+	}
+
+	for _, f := range g.state.Flags {
+		g.drawFlag(world, f)
+	}
+
+	for _, b := range g.state.Boxes {
+		g.drawBox(world, b)
+	}
+
+	for _, it := range g.state.Items {
+		g.drawItem(world, it)
+	}
+
+	for _, p := range g.state.Players {
+		g.drawPlayer(world, p)
 	}
 
 	for _, e := range g.state.Enemies {
-		drawEnemy(screen, e)
+		g.drawEnemy(world, e)
 	}
 
 	for _, b := range g.state.Bullets {
-		clr := color.White
-		if b.From == "enemy" {
-			clr = color.Gray16{0x8888}
-		}
-		ebitenutil.DrawCircle(screen, b.X, b.Y, 2, clr)
+		drawBullet(world, b)
 	}
 
-	scoreStr := fmt.Sprintf("Pontos: %d  Nível: %d", g.state.Points, g.state.Level)
-	ebitenutil.DebugPrintAt(screen, scoreStr, screenWidth/2-100, 0)
+	g.drawWeather(world)
+	g.drawDarkness(world)
+}
 
-	if g.state.GameOver {
-		gameOverStr := "Você Perdeu! Pressione R para Recomeçar"
-		ebitenutil.DebugPrintAt(screen, gameOverStr, screenWidth/2-100, screenHeight/2)
+// drawLeaderboard prints the server's best runs for one period.
+func drawLeaderboard(screen *ebiten.Image, board *Leaderboard, x, y int) {
+	ebitenutil.DrawRect(screen, float64(x-10), float64(y-6), 460, float64(52+16*max(len(board.Entries), 1)), color.RGBA{A: 180})
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Recordes %s - %s  (L muda o período)", periodNames[board.Period], modeNames[board.Mode]), x, y)
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%-3s %-12s %7s %5s %7s %s", "#", "Jogador", "Pontos", "Nível", "Tempo", "Data"), x, y+16)
+	if len(board.Entries) == 0 {
+		ebitenutil.DebugPrintAt(screen, "Nenhuma partida registrada.", x, y+32)
+	}
+	for i, e := range board.Entries {
+		d := time.Duration(e.Duration * float64(time.Second)).Round(time.Second)
+		row := fmt.Sprintf("%-3d %-12.12s %7d %5d %7s %s", i+1, e.Name, e.Score, e.Level, d, e.Time.Local().Format("02/01 15:04"))
+		ebitenutil.DebugPrintAt(screen, row, x, y+16*(i+2))
 	}
 }
 
+// drawScoreboard prints the end-of-match table, best score first.
+func drawScoreboard(screen *ebiten.Image, board *Scoreboard, x, y int) {
+	ebitenutil.DrawRect(screen, float64(x-10), float64(y-6), 510, float64(36+16*len(board.Players)), color.RGBA{A: 180})
+	header := fmt.Sprintf("%-3s %-12s %7s %6s %6s %8s %6s %6s %8s", "#", "Jogador", "Pontos", "Abates", "Mortes", "Precisão", "Dano", "Combo", "Reviveu")
+	ebitenutil.DebugPrintAt(screen, header, x, y)
+	for i, line := range board.Players {
+		row := fmt.Sprintf("%-3d %-12.12s %7d %6d %6d %7.0f%% %6d %6d %8d",
+			i+1, line.Name, line.Score, line.Kills, line.Deaths, line.Accuracy*100, line.DamageTaken, line.BestCombo, line.Revives)
+		ebitenutil.DebugPrintAt(screen, row, x, y+16*(i+1))
+		if line.Team > 0 {
+			ebitenutil.DrawRect(screen, float64(x-8), float64(y+16*(i+1)+4), 4, 8, teamColors[teamIndex(line.Team)])
+		}
+	}
+	total := fmt.Sprintf("Total da equipe: %d  (nível %d)", board.Points, board.Level)
+	if v := board.Versus; v != nil && len(v.Wins) >= 2 {
+		total = fmt.Sprintf("%s %d x %d %s em %d rodadas", teamNames[1], v.Wins[0], v.Wins[1], teamNames[2], v.Round)
+		if v.FreeForAll {
+			total = "Vitórias:"
+			for _, line := range board.Players {
+				if line.Team > 0 && line.Team <= len(v.Wins) {
+					total += fmt.Sprintf(" %s %d,", line.Name, v.Wins[line.Team-1])
+				}
+			}
+			total = strings.TrimSuffix(total, ",") + fmt.Sprintf(" em %d rodadas", v.Round)
+		}
+	}
+	ebitenutil.DebugPrintAt(screen, total, x, y+16*(len(board.Players)+1))
+}
+
 func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
 	return screenWidth, screenHeight
 }
 
 func main() {
-	playerID := "player1"
-	if len(os.Args) > 1 {
-		playerID = os.Args[1]
-	}
+	replay := flag.String("replay", "", "arquivo de replay para assistir em vez de jogar")
+	flag.Parse()
+	username := flag.Arg(0)
 
-	img, _, err := image.Decode(bytes.NewReader(images.Runner_png))
+	for _, name := range characters {
+		characterImages[name] = map[string]*ebiten.Image{}
+		for state, file := range characterAnims {
+			img, _, err := ebitenutil.NewImageFromFile("assets/Main Characters/" + name + "/" + file)
+			if err != nil {
+				log.Fatal(err)
+			}
+			characterImages[name][state] = img
+		}
+	}
+	for typ, path := range projectileAssets {
+		img, _, err := ebitenutil.NewImageFromFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		projectileImages[typ] = img
+	}
+	for kind, name := range fruitNames {
+		img, _, err := ebitenutil.NewImageFromFile("assets/Items/Fruits/" + name + ".png")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fruitImages[kind] = img
+	}
+	var err error
+	collectedImage, _, err = ebitenutil.NewImageFromFile("assets/Items/Fruits/Collected.png")
 	if err != nil {
 		log.Fatal(err)
 	}
-	runnerImage = ebiten.NewImageFromImage(img)
+	for name, path := range levelAssets {
+		img, _, err := ebitenutil.NewImageFromFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		levelImages[name] = img
+	}
+	for kind, s := range enemySprites {
+		for state, path := range map[string]string{"idle": s.idle, "hit": s.hit} {
+			img, _, err := ebitenutil.NewImageFromFile(path)
+			if err != nil {
+				log.Fatal(err)
+			}
+			enemyImages[kind+"/"+state] = img
+		}
+	}
 
 	game := NewGame()
-	game.localPlayerID = playerID
-	game.connectWebSocket()
+	if *replay != "" {
+		if game.replay, err = loadReplay(*replay); err != nil {
+			log.Fatal("Erro ao abrir o replay: ", err)
+		}
+	} else {
+		game.login = newLoginScreen(username)
+	}
 	ebiten.SetWindowSize(screenWidth, screenHeight)
 	ebiten.SetWindowTitle("Jogo Multiplayer com WebSocket e Ebiten")
 	if err := ebiten.RunGame(game); err != nil {
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+)
+
+// Moon is the moon's position, as sent by the server. It is only up at night.
+type Moon struct {
+	X  float64 `json:"x"`
+	Y  float64 `json:"y"`
+	Up bool    `json:"up"`
+}
+
+// skyKey is the look of the sky at one time of day. DayTime runs from 0 at
+// sunrise through 0.5 at sunset back to 1.
+type skyKey struct {
+	at          float64
+	top, bottom color.RGBA
+	light       float64
+}
+
+// skyKeys are blended linearly; the last one wraps around to the first.
+var skyKeys = []skyKey{
+	{0.00, color.RGBA{90, 110, 180, 255}, color.RGBA{250, 170, 130, 255}, 0.75},
+	{0.12, color.RGBA{60, 140, 230, 255}, color.RGBA{170, 210, 250, 255}, 1},
+	{0.40, color.RGBA{60, 140, 230, 255}, color.RGBA{170, 210, 250, 255}, 1},
+	{0.50, color.RGBA{70, 50, 120, 255}, color.RGBA{240, 120, 80, 255}, 0.7},
+	{0.60, color.RGBA{10, 10, 35, 255}, color.RGBA{30, 30, 75, 255}, 0.3},
+	{0.75, color.RGBA{5, 5, 20, 255}, color.RGBA{20, 25, 60, 255}, 0.2},
+	{0.92, color.RGBA{15, 15, 40, 255}, color.RGBA{40, 40, 90, 255}, 0.3},
+	{1.00, color.RGBA{90, 110, 180, 255}, color.RGBA{250, 170, 130, 255}, 0.75},
+}
+
+const (
+	skyBands    = 50
+	moonRadius  = 30.0
+	playerLight = 130.0
+	bulletLight = 30.0
+	moonLight   = 70.0
+	starCount   = 60
+)
+
+var (
+	lightImage *ebiten.Image
+	darkness   *ebiten.Image
+)
+
+// skyAt returns the sky colors and ambient light at time of day t.
+func skyAt(t float64) (top, bottom color.RGBA, light float64) {
+	t = math.Mod(t, 1)
+	for i := 1; i < len(skyKeys); i++ {
+		a, b := skyKeys[i-1], skyKeys[i]
+		if t > b.at {
+			continue
+		}
+		f := (t - a.at) / (b.at - a.at)
+		return mixColor(a.top, b.top, f), mixColor(a.bottom, b.bottom, f), a.light + (b.light-a.light)*f
+	}
+	k := skyKeys[0]
+	return k.top, k.bottom, k.light
+}
+
+func mixColor(a, b color.RGBA, f float64) color.RGBA {
+	mix := func(x, y uint8) uint8 { return uint8(float64(x) + (float64(y)-float64(x))*f) }
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
+
+// skyColorAt is the gradient's color at height y.
+func skyColorAt(top, bottom color.RGBA, y float64) color.RGBA {
+	return mixColor(top, bottom, math.Min(math.Max(y/groundY, 0), 1))
+}
+
+// drawSky paints the gradient, the stars and the sun or moon.
+func (g *Game) drawSky(world *ebiten.Image) {
+	top, bottom, light := skyAt(g.state.DayTime)
+	band := float64(groundY) / skyBands
+	for i := range skyBands {
+		y := float64(i) * band
+		ebitenutil.DrawRect(world, 0, y, screenWidth, band+1, skyColorAt(top, bottom, y+band/2))
+	}
+
+	if stars := 1 - light/0.7; stars > 0 {
+		for i := range starCount {
+			x := float64((i*137 + 53) % screenWidth)
+			y := float64((i*89 + 17) % (groundY * 2 / 3))
+			twinkle := 0.6 + 0.4*math.Sin(float64(g.count)/20+float64(i))
+			a := uint8(255 * math.Min(stars, 1) * twinkle)
+			ebitenutil.DrawRect(world, x, y, 2, 2, color.RGBA{a, a, a, a})
+		}
+	}
+
+	if !g.state.Night {
+		drawFilledCircle(world, g.state.Sun.X, g.state.Sun.Y, 40, g.state.Sun.Color)
+	}
+	if m := g.state.Moon; m.Up {
+		drawFilledCircle(world, m.X, m.Y, moonRadius, color.RGBA{235, 235, 210, 255})
+		drawFilledCircle(world, m.X+12, m.Y-6, moonRadius*0.85, skyColorAt(top, bottom, m.Y))
+	}
+}
+
+// drawDarkness dims the world as the ambient light drops, leaving pools of
+// light around players, their shots and the moon.
+func (g *Game) drawDarkness(world *ebiten.Image) {
+	_, _, light := skyAt(g.state.DayTime)
+	if light >= 1 {
+		return
+	}
+	if darkness == nil {
+		darkness = ebiten.NewImage(screenWidth, screenHeight)
+	}
+	darkness.Fill(color.RGBA{A: uint8(255 * (1 - light))})
+
+	for _, p := range g.state.Players {
+		if !p.Out {
+			cutLight(darkness, p.X, p.Y-playerHeight/2, playerLight)
+		}
+	}
+	for _, b := range g.state.Bullets {
+		cutLight(darkness, b.X, b.Y, bulletLight)
+	}
+	if g.state.Moon.Up {
+		cutLight(darkness, g.state.Moon.X, g.state.Moon.Y, moonLight)
+	}
+	world.DrawImage(darkness, nil)
+}
+
+// cutLight clears a soft-edged disc of the given radius out of mask.
+func cutLight(mask *ebiten.Image, x, y, radius float64) {
+	if lightImage == nil {
+		lightImage = newLightImage(128)
+	}
+	size := float64(lightImage.Bounds().Dx())
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(2*radius/size, 2*radius/size)
+	op.GeoM.Translate(x-radius, y-radius)
+	op.Blend = ebiten.BlendDestinationOut
+	mask.DrawImage(lightImage, op)
+}
+
+// newLightImage is a white disc that is opaque in the middle and fades out
+// toward its edge.
+func newLightImage(size int) *ebiten.Image {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	r := float64(size) / 2
+	for y := range size {
+		for x := range size {
+			d := math.Hypot(float64(x)+0.5-r, float64(y)+0.5-r) / r
+			a := uint8(255 * math.Min(math.Max((1-d)*2, 0), 1))
+			img.SetRGBA(x, y, color.RGBA{a, a, a, a})
+		}
+	}
+	return ebiten.NewImageFromImage(img)
+}
+
+// enemySprites are the enemies drawn from a sprite sheet instead of as stick
+// figures: the night ones.
+var enemySprites = map[string]struct {
+	idle, hit string
+	w, h      int
+	scale     float64
+}{
+	"ghost": {"assets/Enemies/Ghost/Idle (44x30).png", "assets/Enemies/Ghost/Hit (44x30).png", 44, 30, 1.3},
+	"bat":   {"assets/Enemies/Bat/Flying (46x30).png", "assets/Enemies/Bat/Hit (46x30).png", 46, 30, 1},
+}
+
+// drawEnemySprite draws e from its sheet and reports whether it has one.
+// The sheets face left, the way enemies walk.
+func (g *Game) drawEnemySprite(screen *ebiten.Image, e *Enemy) bool {
+	s, ok := enemySprites[e.Kind]
+	if !ok {
+		return false
+	}
+	img, frame := enemyImages[e.Kind+"/idle"], g.count/5
+	if img != nil {
+		frame %= max(img.Bounds().Dx()/s.w, 1)
+	}
+	if e.Dead {
+		img, frame = enemyImages[e.Kind+"/hit"], int(e.DeathTimer/0.08)
+	}
+	op := &ebiten.DrawImageOptions{}
+	w, h := float64(s.w)*s.scale, float64(s.h)*s.scale
+	if e.Vx > 0 {
+		op.GeoM.Scale(-1, 1)
+		op.GeoM.Translate(float64(s.w), 0)
+	}
+	op.GeoM.Scale(s.scale, s.scale)
+	drawSpriteFrame(screen, img, frame, s.w, s.h, e.X-w/2, e.Y+20-h, op)
+	return true
+}
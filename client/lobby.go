@@ -0,0 +1,400 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const maxRoomPlayers = 8
+
+type RoomInfo struct {
+	Code         string       `json:"code"`
+	Name         string       `json:"name"`
+	Public       bool         `json:"public"`
+	MaxPlayers   int          `json:"maxPlayers"`
+	Players      int          `json:"players"`
+	Mode         string       `json:"mode"`
+	Host         string       `json:"host"`
+	Level        int          `json:"level"`
+	Started      bool         `json:"started"`
+	Spectators   int          `json:"spectators"`
+	Delay        float64      `json:"delay,omitempty"`
+	Bots         int          `json:"bots"`
+	Difficulty   string       `json:"difficulty"`
+	Rounds       int          `json:"rounds"`
+	FriendlyFire bool         `json:"friendlyFire"`
+	FreeForAll   bool         `json:"freeForAll"`
+	Members      []MemberInfo `json:"members,omitempty"`
+}
+
+type MemberInfo struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Ready     bool   `json:"ready"`
+	Spectator bool   `json:"spectator,omitempty"`
+}
+
+// botDifficulties are the bot levels the N key cycles through, with their
+// names.
+var (
+	botDifficulties = []string{"easy", "normal", "hard"}
+	difficultyNames = map[string]string{"easy": "fácil", "normal": "normal", "hard": "difícil"}
+)
+
+// gameModes are the modes the host cycles through with M, with their names.
+var (
+	gameModes = []string{"coop", "timeattack", "endless", "versus"}
+	modeNames = map[string]string{
+		"coop":       "sobrevivência",
+		"timeattack": "contra o tempo",
+		"endless":    "infinito",
+		"versus":     "versus",
+	}
+)
+
+// spectatorDelays are the spectator delays the D key cycles through when
+// creating a room.
+var spectatorDelays = []float64{0, 5, 15, 30}
+
+// lobbyScreen is shown between login and the match: the public room list,
+// room creation, join by code and quick match, then the room itself until
+// the host starts.
+type lobbyScreen struct {
+	rooms      []RoomInfo
+	selected   int
+	status     string
+	queued     bool
+	typingCode bool
+	watchCode  bool
+	code       string
+	public     bool
+	maxPlayers int
+	delayIndex int
+}
+
+func newLobbyScreen() *lobbyScreen {
+	return &lobbyScreen{public: true, maxPlayers: 4}
+}
+
+func (g *Game) sendLobby(m Message) {
+	m.Type = "lobby"
+	m.PlayerID = g.localPlayerID
+	g.sendMessage(m)
+}
+
+// handleLobbyMessage applies a lobby message from the server and reports
+// whether msg was one.
+func (g *Game) handleLobbyMessage(typ string, msg []byte) bool {
+	l := g.lobby
+	switch typ {
+	case "rooms":
+		var list struct {
+			Rooms []RoomInfo `json:"rooms"`
+		}
+		if err := json.Unmarshal(msg, &list); err != nil {
+			log.Println("Erro ao decodificar salas:", err)
+			return true
+		}
+		l.rooms = list.Rooms
+		l.selected = min(l.selected, max(len(l.rooms)-1, 0))
+	case "room":
+		var m struct {
+			Room RoomInfo `json:"room"`
+		}
+		if err := json.Unmarshal(msg, &m); err != nil {
+			log.Println("Erro ao decodificar sala:", err)
+			return true
+		}
+		if g.room == nil {
+			l.selected = 0
+		}
+		g.room = &m.Room
+		l.queued = false
+		l.status = ""
+	case "left":
+		g.camera, g.following = newCamera(), ""
+		var m struct {
+			Reason string `json:"reason"`
+		}
+		json.Unmarshal(msg, &m)
+		g.room = nil
+		g.state = GameState{Players: map[string]*Player{}}
+		g.scoreboard, g.leaderboard = nil, nil
+		l.selected = 0
+		l.status = ""
+		switch m.Reason {
+		case "kicked":
+			l.status = "Você foi expulso da sala."
+		case "closed":
+			l.status = "A sala foi fechada."
+		}
+	case "queued":
+		l.queued = true
+		l.status = "Procurando partida..."
+	case "error":
+		var m struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(msg, &m)
+		l.status = m.Error
+	default:
+		return false
+	}
+	return true
+}
+
+// inMatch reports whether the game screen should be shown.
+func (g *Game) inMatch() bool {
+	return g.replay != nil || (g.room != nil && g.room.Started)
+}
+
+// spectating reports whether the local client watches its room instead of
+// playing in it.
+func (g *Game) spectating() bool {
+	if g.room == nil {
+		return false
+	}
+	i := slices.IndexFunc(g.room.Members, func(m MemberInfo) bool { return m.ID == g.localPlayerID })
+	return i >= 0 && g.room.Members[i].Spectator
+}
+
+func (g *Game) isHost() bool {
+	return g.room != nil && g.room.Host == g.localPlayerID
+}
+
+func (g *Game) updateLobby() {
+	l := g.lobby
+	if l.typingCode {
+		l.code += strings.ToUpper(string(ebiten.AppendInputChars(nil)))
+		switch {
+		case inpututil.IsKeyJustPressed(ebiten.KeyBackspace) && l.code != "":
+			l.code = l.code[:len(l.code)-1]
+		case inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+			command := "join"
+			if l.watchCode {
+				command = "spectate"
+			}
+			g.sendLobby(Message{Command: command, Room: l.code})
+			l.typingCode = false
+		case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+			l.typingCode = false
+		}
+		return
+	}
+	if g.room != nil {
+		g.updateRoomLobby()
+		return
+	}
+
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowUp):
+		l.selected = max(l.selected-1, 0)
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowDown):
+		l.selected = min(l.selected+1, max(len(l.rooms)-1, 0))
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter) && l.selected < len(l.rooms):
+		g.sendLobby(Message{Command: "join", Room: l.rooms[l.selected].Code})
+	case inpututil.IsKeyJustPressed(ebiten.KeyV) && l.selected < len(l.rooms):
+		g.sendLobby(Message{Command: "spectate", Room: l.rooms[l.selected].Code})
+	case inpututil.IsKeyJustPressed(ebiten.KeyC):
+		g.sendLobby(Message{Command: "create", Public: l.public, MaxPlayers: l.maxPlayers, Delay: spectatorDelays[l.delayIndex]})
+	case inpututil.IsKeyJustPressed(ebiten.KeyD):
+		l.delayIndex = (l.delayIndex + 1) % len(spectatorDelays)
+	case inpututil.IsKeyJustPressed(ebiten.KeyP):
+		l.public = !l.public
+	case inpututil.IsKeyJustPressed(ebiten.KeyEqual), inpututil.IsKeyJustPressed(ebiten.KeyKPAdd):
+		l.maxPlayers = min(l.maxPlayers+1, maxRoomPlayers)
+	case inpututil.IsKeyJustPressed(ebiten.KeyMinus), inpututil.IsKeyJustPressed(ebiten.KeyKPSubtract):
+		l.maxPlayers = max(l.maxPlayers-1, 1)
+	case inpututil.IsKeyJustPressed(ebiten.KeyJ):
+		l.typingCode, l.watchCode, l.code = true, false, ""
+	case inpututil.IsKeyJustPressed(ebiten.KeyW):
+		l.typingCode, l.watchCode, l.code = true, true, ""
+	case inpututil.IsKeyJustPressed(ebiten.KeyQ):
+		if l.queued {
+			g.sendLobby(Message{Command: "cancel"})
+			l.queued, l.status = false, ""
+		} else {
+			g.sendLobby(Message{Command: "quick"})
+		}
+	case inpututil.IsKeyJustPressed(ebiten.KeyF5):
+		g.sendLobby(Message{Command: "list"})
+	}
+}
+
+func (g *Game) updateRoomLobby() {
+	l, r := g.lobby, g.room
+	me := slices.IndexFunc(r.Members, func(m MemberInfo) bool { return m.ID == g.localPlayerID })
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		g.sendLobby(Message{Command: "leave"})
+	case inpututil.IsKeyJustPressed(ebiten.KeyR) && me >= 0:
+		g.sendLobby(Message{Command: "ready", Ready: !r.Members[me].Ready})
+	case !g.isHost():
+	case inpututil.IsKeyJustPressed(ebiten.KeyS):
+		g.sendLobby(Message{Command: "start"})
+	case inpututil.IsKeyJustPressed(ebiten.KeyB):
+		g.sendLobby(Message{Command: "bots", Bots: (r.Bots + 1) % r.MaxPlayers, Difficulty: r.Difficulty})
+	case inpututil.IsKeyJustPressed(ebiten.KeyN):
+		i := (slices.Index(botDifficulties, r.Difficulty) + 1) % len(botDifficulties)
+		g.sendLobby(Message{Command: "bots", Bots: r.Bots, Difficulty: botDifficulties[i]})
+	case inpututil.IsKeyJustPressed(ebiten.KeyM):
+		i := (slices.Index(gameModes, r.Mode) + 1) % len(gameModes)
+		g.sendLobby(Message{Command: "mode", Mode: gameModes[i]})
+	case inpututil.IsKeyJustPressed(ebiten.KeyT) && r.Mode == "versus":
+		i := (slices.Index(versusRounds, r.Rounds) + 1) % len(versusRounds)
+		g.sendLobby(Message{Command: "mode", Rounds: versusRounds[i]})
+	case inpututil.IsKeyJustPressed(ebiten.KeyF) && r.Mode == "versus":
+		g.sendLobby(Message{Command: "rules", FriendlyFire: !r.FriendlyFire, FreeForAll: r.FreeForAll})
+	case inpututil.IsKeyJustPressed(ebiten.KeyG) && r.Mode == "versus":
+		g.sendLobby(Message{Command: "rules", FriendlyFire: r.FriendlyFire, FreeForAll: !r.FreeForAll})
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowUp):
+		l.selected = max(l.selected-1, 0)
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowDown):
+		l.selected = min(l.selected+1, max(len(r.Members)-1, 0))
+	case inpututil.IsKeyJustPressed(ebiten.KeyK) && l.selected < len(r.Members):
+		g.sendLobby(Message{Command: "kick", Target: r.Members[l.selected].ID})
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) && r.Level > 1:
+		g.sendLobby(Message{Command: "level", Level: r.Level - 1})
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowRight):
+		g.sendLobby(Message{Command: "level", Level: r.Level + 1})
+	}
+}
+
+// updateMatchControls handles the room keys available during a match:
+// Esc leaves, and the host can move between levels with [ and ].
+func (g *Game) updateMatchControls() {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		g.sendLobby(Message{Command: "leave"})
+	case !g.isHost():
+	case inpututil.IsKeyJustPressed(ebiten.KeyBracketLeft) && g.state.Level > 1:
+		g.sendLobby(Message{Command: "level", Level: g.state.Level - 1})
+	case inpututil.IsKeyJustPressed(ebiten.KeyBracketRight):
+		g.sendLobby(Message{Command: "level", Level: g.state.Level + 1})
+	}
+}
+
+func (g *Game) drawSpectatorHUD(screen *ebiten.Image) {
+	name := "ninguém"
+	if p := g.state.Players[g.following]; p != nil {
+		name = p.Name
+	}
+	line := fmt.Sprintf("Assistindo %s   Setas: trocar jogador   Z: zoom   Esc: sair", name)
+	if g.room.Delay > 0 {
+		line += fmt.Sprintf("   (atraso de %.0f s)", g.room.Delay)
+	}
+	ebitenutil.DebugPrintAt(screen, line, 10, screenHeight-20)
+}
+
+func (g *Game) drawLobby(screen *ebiten.Image) {
+	l := g.lobby
+	screen.Fill(color.RGBA{R: 30, G: 30, B: 80, A: 255})
+	x, y := 60, 40
+	if g.room != nil {
+		g.drawRoomLobby(screen, x, y)
+	} else {
+		ebitenutil.DebugPrintAt(screen, "Salas públicas", x, y)
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%-2s %-6s %-24s %-9s %-14s %s", "", "Código", "Nome", "Jogadores", "Modo", "Estado"), x, y+24)
+		if len(l.rooms) == 0 {
+			ebitenutil.DebugPrintAt(screen, "Nenhuma sala aberta.", x, y+44)
+		}
+		for i, r := range l.rooms {
+			cursor := "  "
+			if i == l.selected {
+				cursor = "> "
+			}
+			status := "no lobby"
+			if r.Started {
+				status = fmt.Sprintf("jogando (nível %d)", r.Level)
+			}
+			if r.Spectators > 0 {
+				status += fmt.Sprintf(", %d assistindo", r.Spectators)
+			}
+			row := fmt.Sprintf("%-2s %-6s %-24.24s %4d/%-4d %-14s %s", cursor, r.Code, r.Name, r.Players, r.MaxPlayers, modeNames[r.Mode], status)
+			ebitenutil.DebugPrintAt(screen, row, x, y+44+i*16)
+		}
+		visibility := "pública"
+		if !l.public {
+			visibility = "privada"
+		}
+		help := []string{
+			"Enter: entrar na sala   V: assistir   J/W: entrar/assistir por código",
+			"Q: partida rápida   F5: atualizar",
+			fmt.Sprintf("C: criar sala %s para %d jogadores, espectadores com %.0f s de atraso", visibility, l.maxPlayers, spectatorDelays[l.delayIndex]),
+			"P: pública/privada   +/-: jogadores   D: atraso dos espectadores",
+		}
+		for i, line := range help {
+			ebitenutil.DebugPrintAt(screen, line, x, screenHeight-120+i*16)
+		}
+	}
+	if l.typingCode {
+		ebitenutil.DrawRect(screen, float64(x-10), float64(screenHeight/2-20), 300, 40, color.RGBA{A: 200})
+		prompt := "Código da sala: "
+		if l.watchCode {
+			prompt = "Assistir à sala: "
+		}
+		ebitenutil.DebugPrintAt(screen, prompt+l.code+"_", x, screenHeight/2-8)
+	}
+	ebitenutil.DebugPrintAt(screen, l.status, x, screenHeight-40)
+}
+
+func (g *Game) drawRoomLobby(screen *ebiten.Image, x, y int) {
+	r := g.room
+	visibility := "pública"
+	if !r.Public {
+		visibility = "privada"
+	}
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Sala %s  (código %s, %s)", r.Name, r.Code, visibility), x, y)
+	line := fmt.Sprintf("Modo: %s   Nível inicial: %d   Jogadores: %d/%d", modeNames[r.Mode], r.Level, r.Players, r.MaxPlayers)
+	if r.Mode == "versus" {
+		line += fmt.Sprintf("   Vence quem fizer %d rodadas", r.Rounds)
+		if r.FreeForAll {
+			line += "   Todos contra todos"
+		}
+		if r.FriendlyFire {
+			line += "   Fogo amigo"
+		}
+	}
+	if r.Bots > 0 {
+		line += fmt.Sprintf("   Bots: até %d (%s)", r.Bots, difficultyNames[r.Difficulty])
+	}
+	ebitenutil.DebugPrintAt(screen, line, x, y+20)
+	for i, m := range r.Members {
+		cursor := "  "
+		if g.isHost() && i == g.lobby.selected {
+			cursor = "> "
+		}
+		ready := "esperando"
+		switch {
+		case m.Spectator:
+			ready = "espectador"
+		case m.ID == r.Host:
+			ready = "anfitrião"
+		case m.Ready:
+			ready = "pronto"
+		}
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%s%-20.20s %s", cursor, m.Name, ready), x, y+50+i*16)
+	}
+	help := "R: pronto   Esc: sair da sala"
+	switch {
+	case g.spectating():
+		help = "Assistindo — a partida começa quando o anfitrião iniciar.   Esc: sair da sala"
+	case g.isHost():
+		help = "S: iniciar   K: expulsar   Setas: escolher jogador / nível   B/N: bots / dificuldade   M/T: modo / rodadas   Esc: sair da sala"
+		if r.Mode == "versus" {
+			help += "\nF: fogo amigo   G: todos contra todos"
+		}
+	}
+	if r.Delay > 0 {
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Espectadores veem a partida com %.0f s de atraso.", r.Delay), x, screenHeight-120)
+	}
+	ebitenutil.DebugPrintAt(screen, help, x, screenHeight-100)
+}
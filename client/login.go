@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// loginField is one text box of the login screen.
+type loginField struct {
+	Label  string
+	Value  string
+	Secret bool
+}
+
+// loginScreen collects credentials and trades them for a session token
+// before the game connects. The exchange runs in the background and hands
+// its result to Update over done.
+type loginScreen struct {
+	fields  []loginField
+	focus   int
+	status  string
+	pending bool
+	done    chan loginResult
+}
+
+// loginResult is how an authentication attempt ended: a connection for
+// the session, or the status to show when it failed.
+type loginResult struct {
+	session session
+	conn    *websocket.Conn
+	status  string
+}
+
+type session struct {
+	Token string `json:"token"`
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+}
+
+func newLoginScreen(username string) *loginScreen {
+	l := &loginScreen{fields: []loginField{
+		{Label: "Usuário", Value: username},
+		{Label: "Senha", Secret: true},
+		{Label: "Nome de exibição (só ao criar conta)"},
+	}, done: make(chan loginResult, 1)}
+	if username != "" {
+		l.focus = 1
+	}
+	return l
+}
+
+func (g *Game) updateLogin() {
+	l := g.login
+	if l.pending {
+		select {
+		case res := <-l.done:
+			if res.conn == nil {
+				l.status, l.pending = res.status, false
+				return
+			}
+			g.attach(res.session, res.conn)
+			g.login = nil
+		default:
+		}
+		return
+	}
+	f := &l.fields[l.focus]
+	f.Value += string(ebiten.AppendInputChars(nil))
+	if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) && f.Value != "" {
+		r := []rune(f.Value)
+		f.Value = string(r[:len(r)-1])
+	}
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyTab), inpututil.IsKeyJustPressed(ebiten.KeyDown):
+		l.focus = (l.focus + 1) % len(l.fields)
+	case inpututil.IsKeyJustPressed(ebiten.KeyUp):
+		l.focus = (l.focus + len(l.fields) - 1) % len(l.fields)
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+		l.pending = true
+		l.status = "Entrando..."
+		go authenticate("/login", l.credentials(), l.done)
+	case inpututil.IsKeyJustPressed(ebiten.KeyF2):
+		l.pending = true
+		l.status = "Criando conta..."
+		go authenticate("/register", l.credentials(), l.done)
+	}
+}
+
+func (l *loginScreen) credentials() map[string]string {
+	return map[string]string{
+		"username": l.fields[0].Value,
+		"password": l.fields[1].Value,
+		"name":     l.fields[2].Value,
+	}
+}
+
+// authenticate posts the credentials to path (/login or /register) and
+// connects once the server hands back a session, reporting on done. Servers
+// running without accounts answer 503; the client then joins with the user
+// name as its ID.
+func authenticate(path string, cred map[string]string, done chan<- loginResult) {
+	body, _ := json.Marshal(cred)
+	u := url.URL{Scheme: "http", Host: serverAddr, Path: path}
+	resp, err := http.Post(u.String(), "application/json", bytes.NewReader(body))
+	if err != nil {
+		done <- loginResult{status: fmt.Sprintf("Servidor indisponível: %v", err)}
+		return
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+
+	var s session
+	switch {
+	case resp.StatusCode == http.StatusServiceUnavailable:
+		s = session{ID: strings.TrimSpace(cred["username"])}
+		if s.ID == "" {
+			s.ID = "player1"
+		}
+	case resp.StatusCode >= 300:
+		done <- loginResult{status: string(data)}
+		return
+	default:
+		if err := json.Unmarshal(data, &s); err != nil {
+			done <- loginResult{status: "Resposta inválida do servidor"}
+			return
+		}
+	}
+	conn, err := dialServer(s)
+	if err != nil {
+		log.Println("Erro na conexão WebSocket:", err)
+		done <- loginResult{status: fmt.Sprintf("Erro ao conectar: %v", err)}
+		return
+	}
+	done <- loginResult{session: s, conn: conn}
+}
+
+func (g *Game) drawLogin(screen *ebiten.Image) {
+	l := g.login
+	screen.Fill(color.RGBA{R: 30, G: 30, B: 80, A: 255})
+	x, y := screenWidth/2-160, screenHeight/2-90
+	ebitenutil.DebugPrintAt(screen, "Entrar no jogo", x, y)
+	for i, f := range l.fields {
+		value := f.Value
+		if f.Secret {
+			value = strings.Repeat("*", len([]rune(value)))
+		}
+		if i == l.focus && (g.count/30)%2 == 0 {
+			value += "_"
+		}
+		prefix := "  "
+		if i == l.focus {
+			prefix = "> "
+		}
+		fy := y + 30 + i*36
+		ebitenutil.DebugPrintAt(screen, prefix+f.Label, x, fy)
+		ebitenutil.DrawRect(screen, float64(x+16), float64(fy+16), 300, 16, color.RGBA{A: 160})
+		ebitenutil.DebugPrintAt(screen, value, x+20, fy+16)
+	}
+	ebitenutil.DebugPrintAt(screen, "Enter: entrar   F2: criar conta   Tab: próximo campo", x, y+150)
+	ebitenutil.DebugPrintAt(screen, l.status, x, y+170)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	replaySeekStep = 5.0
+	replayPanSpeed = 8.0
+	replayMaxZoom  = 4.0
+)
+
+// replaySpeeds are the playback speeds Up/Down step through.
+var replaySpeeds = []float64{0.25, 0.5, 1, 2, 4, 8}
+
+// replayHeader is the first line of a replay file written by the server.
+type replayHeader struct {
+	Room     string    `json:"room"`
+	Mode     string    `json:"mode"`
+	Seed     uint64    `json:"seed"`
+	TickRate int       `json:"tickRate"`
+	Started  time.Time `json:"started"`
+}
+
+// replayFrame is a message the server sent during the run: a snapshot or,
+// at the end, the scoreboard.
+type replayFrame struct {
+	Tick       int
+	Scoreboard bool
+	Data       json.RawMessage
+}
+
+// replayViewer plays a replay file back. The position is kept in ticks and
+// the frame shown is always the last one at or before it, so seeking is just
+// moving the position.
+type replayViewer struct {
+	name       string
+	header     replayHeader
+	frames     []replayFrame
+	shown      int
+	pos        float64
+	speedIndex int
+	paused     bool
+	free       bool
+	lastMouseX int
+	lastMouseY int
+}
+
+func loadReplay(path string) (*replayViewer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: não é um replay: %w", path, err)
+	}
+	v := &replayViewer{name: filepath.Base(path), shown: -1, speedIndex: 2}
+	dec := json.NewDecoder(gz)
+	if err := dec.Decode(&v.header); err != nil {
+		return nil, fmt.Errorf("%s: cabeçalho inválido: %w", path, err)
+	}
+	if v.header.TickRate <= 0 {
+		v.header.TickRate = 60
+	}
+	for dec.More() {
+		var ev struct {
+			Tick int             `json:"tick"`
+			Kind string          `json:"kind"`
+			Data json.RawMessage `json:"data"`
+		}
+		if err := dec.Decode(&ev); err != nil {
+			// Replays of runs cut short by a server stop end mid-line.
+			break
+		}
+		if ev.Kind != "frame" {
+			continue
+		}
+		var envelope struct {
+			Type string `json:"type"`
+		}
+		json.Unmarshal(ev.Data, &envelope)
+		v.frames = append(v.frames, replayFrame{Tick: ev.Tick, Scoreboard: envelope.Type == "scoreboard", Data: ev.Data})
+	}
+	if len(v.frames) == 0 {
+		return nil, fmt.Errorf("%s: replay sem quadros", path)
+	}
+	return v, nil
+}
+
+func (v *replayViewer) length() float64 {
+	return float64(v.frames[len(v.frames)-1].Tick)
+}
+
+func (v *replayViewer) seconds(ticks float64) float64 {
+	return ticks / float64(v.header.TickRate)
+}
+
+// seek moves the position by d seconds, staying inside the replay.
+func (v *replayViewer) seek(d float64) {
+	v.pos = max(0, min(v.pos+d*float64(v.header.TickRate), v.length()))
+}
+
+// updateReplay advances playback and handles the replay keys. Esc closes
+// the client.
+func (g *Game) updateReplay() error {
+	v := g.replay
+	shift := ebiten.IsKeyPressed(ebiten.KeyShift)
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		return ebiten.Termination
+	case inpututil.IsKeyJustPressed(ebiten.KeySpace):
+		v.paused = !v.paused
+		if !v.paused && v.pos >= v.length() {
+			v.pos = 0
+		}
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) && shift:
+		v.seek(-6 * replaySeekStep)
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowRight) && shift:
+		v.seek(6 * replaySeekStep)
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft):
+		v.seek(-replaySeekStep)
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowRight):
+		v.seek(replaySeekStep)
+	case inpututil.IsKeyJustPressed(ebiten.KeyComma):
+		v.paused = true
+		v.step(-1)
+	case inpututil.IsKeyJustPressed(ebiten.KeyPeriod):
+		v.paused = true
+		v.step(1)
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowUp):
+		v.speedIndex = min(v.speedIndex+1, len(replaySpeeds)-1)
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowDown):
+		v.speedIndex = max(v.speedIndex-1, 0)
+	case inpututil.IsKeyJustPressed(ebiten.KeyHome):
+		v.pos = 0
+	}
+	for k := ebiten.Key0; k <= ebiten.Key9; k++ {
+		if inpututil.IsKeyJustPressed(k) {
+			v.pos = v.length() * float64(k-ebiten.Key0) / 10
+		}
+	}
+
+	if !v.paused {
+		v.pos += replaySpeeds[v.speedIndex] * float64(v.header.TickRate) / float64(ebiten.TPS())
+		if v.pos >= v.length() {
+			v.pos, v.paused = v.length(), true
+		}
+	}
+	g.showReplayFrame()
+	g.updateReplayCamera()
+	return nil
+}
+
+// step moves to the previous or next recorded frame.
+func (v *replayViewer) step(dir int) {
+	i := max(0, min(v.frameAt(v.pos)+dir, len(v.frames)-1))
+	v.pos = float64(v.frames[i].Tick)
+}
+
+// frameAt is the index of the last frame at or before tick pos.
+func (v *replayViewer) frameAt(pos float64) int {
+	i := sort.Search(len(v.frames), func(i int) bool { return float64(v.frames[i].Tick) > pos })
+	return max(i-1, 0)
+}
+
+// showReplayFrame decodes the frame at the current position into the game
+// state, the way readMessages does for live snapshots.
+func (g *Game) showReplayFrame() {
+	v := g.replay
+	i := v.frameAt(v.pos)
+	if i == v.shown {
+		return
+	}
+	v.shown = i
+	g.scoreboard = nil
+	if v.frames[i].Scoreboard {
+		var board Scoreboard
+		if json.Unmarshal(v.frames[i].Data, &board) == nil {
+			g.scoreboard = &board
+		}
+		// The scoreboard follows the final snapshot; keep showing it.
+		for i > 0 && v.frames[i].Scoreboard {
+			i--
+		}
+	}
+	var state GameState
+	if json.Unmarshal(v.frames[i].Data, &state) == nil {
+		g.state = state
+	}
+}
+
+// updateReplayCamera moves the free camera with WASD, mouse drag and the
+// wheel, or follows a player picked with Tab. F frees the camera again.
+func (g *Game) updateReplayCamera() {
+	v := g.replay
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyTab):
+		v.free = false
+		g.cycleFollowing(1)
+	case inpututil.IsKeyJustPressed(ebiten.KeyF):
+		v.free = true
+	case inpututil.IsKeyJustPressed(ebiten.KeyZ):
+		g.toggleZoom()
+	}
+	if _, wy := ebiten.Wheel(); wy != 0 {
+		g.camera.Zoom = max(1, min(g.camera.Zoom*(1+wy*0.1), replayMaxZoom))
+		g.camera.clamp()
+	}
+
+	dx, dy := 0.0, 0.0
+	if ebiten.IsKeyPressed(ebiten.KeyA) {
+		dx--
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyD) {
+		dx++
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyW) {
+		dy--
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyS) {
+		dy++
+	}
+	mx, my := ebiten.CursorPosition()
+	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) && !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		g.camera.X -= float64(mx-v.lastMouseX) / g.camera.Zoom
+		g.camera.Y -= float64(my-v.lastMouseY) / g.camera.Zoom
+		v.free = true
+	}
+	v.lastMouseX, v.lastMouseY = mx, my
+	if dx != 0 || dy != 0 {
+		g.camera.X += dx * replayPanSpeed / g.camera.Zoom
+		g.camera.Y += dy * replayPanSpeed / g.camera.Zoom
+		v.free = true
+	}
+	g.camera.clamp()
+
+	if !v.free {
+		if g.state.Players[g.following] == nil {
+			g.cycleFollowing(0)
+		}
+		g.followCamera()
+	}
+}
+
+func (g *Game) drawReplayHUD(screen *ebiten.Image) {
+	v := g.replay
+	barX, barY, barW := 10.0, float64(screenHeight-44), float64(screenWidth-20)
+	ebitenutil.DrawRect(screen, barX, barY, barW, 6, color.RGBA{R: 60, G: 60, B: 60, A: 200})
+	ebitenutil.DrawRect(screen, barX, barY, barW*v.pos/max(v.length(), 1), 6, color.RGBA{R: 220, G: 60, B: 60, A: 255})
+
+	state := fmt.Sprintf("%gx", replaySpeeds[v.speedIndex])
+	if v.paused {
+		state = "pausado"
+	}
+	camera := "câmera livre"
+	if !v.free {
+		camera = "seguindo ninguém"
+		if p := g.state.Players[g.following]; p != nil {
+			camera = "seguindo " + p.Name
+		}
+	}
+	elapsed := time.Duration(v.seconds(v.pos) * float64(time.Second)).Round(time.Second)
+	total := time.Duration(v.seconds(v.length()) * float64(time.Second)).Round(time.Second)
+	info := fmt.Sprintf("Replay %s  %s / %s  %s  %s", v.name, elapsed, total, state, camera)
+	ebitenutil.DebugPrintAt(screen, info, 10, screenHeight-36)
+	help := "Espaço: pausar  Esq/Dir: -/+5 s  ,/.: quadro  Cima/Baixo: velocidade  0-9: pular  Tab: seguir  F/WASD: livre  Z: zoom  Esc: sair"
+	ebitenutil.DebugPrintAt(screen, help, 10, screenHeight-20)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+)
+
+// reviveTime and reviveRadius mirror the server's revive rules.
+const (
+	reviveTime   = 3.0
+	reviveRadius = 60.0
+)
+
+var (
+	reviveColor   = color.RGBA{R: 255, G: 220, B: 60, A: 255}
+	bleedOutColor = color.RGBA{R: 230, G: 60, B: 60, A: 255}
+)
+
+// drawReviveMarker draws the circle teammates stand in to revive a downed
+// player, filled as the revive progresses, with the bleed-out time above it.
+func drawReviveMarker(world *ebiten.Image, p *Player) {
+	cx, cy := p.X, p.Y-playerHeight/2
+	clr := bleedOutColor
+	if (int(p.Downed*4))%2 == 0 {
+		clr = reviveColor
+	}
+	drawCircle(world, cx, cy, reviveRadius, clr)
+	if p.Revive > 0 {
+		steps := int(32 * min(p.Revive/reviveTime, 1))
+		for i := range steps {
+			a0 := -math.Pi/2 + 2*math.Pi*float64(i)/32
+			a1 := -math.Pi/2 + 2*math.Pi*float64(i+1)/32
+			ebitenutil.DrawLine(world, cx+(reviveRadius-4)*math.Cos(a0), cy+(reviveRadius-4)*math.Sin(a0),
+				cx+(reviveRadius-4)*math.Cos(a1), cy+(reviveRadius-4)*math.Sin(a1), reviveColor)
+		}
+	}
+	ebitenutil.DebugPrintAt(world, fmt.Sprintf("%ds", int(math.Ceil(p.Downed))), int(cx)-8, int(cy-reviveRadius-16))
+}
+
+// drawDownedHUD points at every downed teammate from the edge of the screen,
+// with their name and bleed-out time, and tells the local player when they
+// are down or out themselves.
+func (g *Game) drawDownedHUD(screen *ebiten.Image) {
+	const margin = 30
+	ids := make([]string, 0, len(g.state.Players))
+	for id, p := range g.state.Players {
+		if p.Downed > 0 && id != g.localPlayerID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		p := g.state.Players[id]
+		// Where the player is on screen, pulled in to the margin when the
+		// camera doesn't show them.
+		x := (p.X-g.camera.X)*g.camera.Zoom + screenWidth/2
+		y := (p.Y-playerHeight/2-g.camera.Y)*g.camera.Zoom + screenHeight/2
+		ex := max(margin, min(x, screenWidth-margin))
+		ey := max(margin+40, min(y-reviveRadius*g.camera.Zoom-30, screenHeight-margin))
+		angle := math.Atan2(y-ey, x-ex)
+		if x == ex && y > ey {
+			angle = math.Pi / 2
+		}
+		tipX, tipY := ex+14*math.Cos(angle), ey+14*math.Sin(angle)
+		for _, side := range []float64{-2.5, 2.5} {
+			ebitenutil.DrawLine(screen, tipX, tipY, ex+8*math.Cos(angle+side), ey+8*math.Sin(angle+side), bleedOutColor)
+		}
+		drawFilledCircle(screen, ex, ey, 5, bleedOutColor)
+		label := fmt.Sprintf("Reviva %s (%ds)", p.Name, int(math.Ceil(p.Downed)))
+		lx := max(4, min(int(ex)-len(label)*3, screenWidth-len(label)*6-4))
+		ebitenutil.DebugPrintAt(screen, label, lx, int(ey)-24)
+	}
+
+	me := g.state.Players[g.localPlayerID]
+	switch {
+	case me == nil:
+	case me.Downed > 0:
+		msg := fmt.Sprintf("Você caiu! Um companheiro pode te reviver em %d s", int(math.Ceil(me.Downed)))
+		if me.Revive > 0 {
+			msg = fmt.Sprintf("Sendo revivido... %.0f%%", 100*min(me.Revive/reviveTime, 1))
+		}
+		ebitenutil.DebugPrintAt(screen, msg, screenWidth/2-150, screenHeight/2-60)
+	case me.Respawn > 0:
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Você volta em %d s", int(math.Ceil(me.Respawn))), screenWidth/2-60, screenHeight/2-60)
+	case me.Out:
+		name := "ninguém"
+		if p := g.state.Players[g.following]; p != nil {
+			name = p.Name
+		}
+		until := "o próximo nível"
+		if g.state.Versus != nil {
+			until = "a próxima rodada"
+		}
+		line := fmt.Sprintf("Você está fora até %s. Assistindo %s   Setas: trocar jogador   Z: zoom", until, name)
+		ebitenutil.DebugPrintAt(screen, line, 10, screenHeight-20)
+	}
+}
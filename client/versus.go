@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+)
+
+// Versus is the round state of a versus match, as sent by the server.
+type Versus struct {
+	Target       int     `json:"target"`
+	Round        int     `json:"round"`
+	Wins         []int   `json:"wins"`
+	RoundWinner  int     `json:"roundWinner"`
+	Break        float64 `json:"break"`
+	Waiting      bool    `json:"waiting"`
+	Winner       int     `json:"winner"`
+	FreeForAll   bool    `json:"freeForAll"`
+	FriendlyFire bool    `json:"friendlyFire"`
+}
+
+// versusRounds are the round targets the host cycles through with T.
+var versusRounds = []int{1, 3, 5, 7, 9}
+
+// Teams are numbered from 1; index 0 is no team. Free-for-all matches have
+// a team per player, so the colors past the first two only show up there
+// and wrap around once they run out.
+var (
+	teamNames  = []string{"", "Vermelho", "Azul", "Verde", "Amarelo", "Roxo", "Laranja", "Ciano", "Rosa"}
+	teamColors = []color.RGBA{
+		{},
+		{R: 230, G: 70, B: 60, A: 255},
+		{R: 70, G: 130, B: 240, A: 255},
+		{R: 70, G: 200, B: 90, A: 255},
+		{R: 240, G: 210, B: 60, A: 255},
+		{R: 160, G: 90, B: 220, A: 255},
+		{R: 240, G: 140, B: 40, A: 255},
+		{R: 60, G: 210, B: 210, A: 255},
+		{R: 240, G: 120, B: 190, A: 255},
+	}
+	teamTints = [][3]float32{
+		{1, 1, 1},
+		{1, 0.6, 0.55},
+		{0.6, 0.75, 1},
+		{0.6, 1, 0.65},
+		{1, 0.95, 0.55},
+		{0.8, 0.6, 1},
+		{1, 0.75, 0.5},
+		{0.55, 1, 1},
+		{1, 0.7, 0.9},
+	}
+)
+
+// teamIndex maps a team to its entry in the tables above, or 0 for none.
+func teamIndex(team int) int {
+	if team <= 0 {
+		return 0
+	}
+	return (team-1)%(len(teamNames)-1) + 1
+}
+
+func teamName(team int) string {
+	return teamNames[teamIndex(team)]
+}
+
+// sideName names a team in messages: "Time Vermelho", or in free-for-all
+// the name of the player who is that team.
+func (g *Game) sideName(team int) string {
+	if v := g.state.Versus; v != nil && v.FreeForAll {
+		for _, p := range g.state.Players {
+			if p.Team == team {
+				return p.Name
+			}
+		}
+	}
+	return "Time " + teamName(team)
+}
+
+// tintTeam colors a player's sprite with their team's color.
+func tintTeam(op *ebiten.DrawImageOptions, team int) {
+	if team <= 0 {
+		return
+	}
+	t := teamTints[teamIndex(team)]
+	op.ColorScale.Scale(t[0], t[1], t[2], 1)
+}
+
+// drawTeamMarker draws a bar in the team's color under a player's feet.
+func drawTeamMarker(world *ebiten.Image, p *Player) {
+	if p.Team <= 0 {
+		return
+	}
+	ebitenutil.DrawRect(world, p.X-12, p.Y+2, 24, 3, teamColors[teamIndex(p.Team)])
+}
+
+// drawVersusHUD shows the round score and what happens between rounds.
+func (g *Game) drawVersusHUD(screen *ebiten.Image) {
+	v := g.state.Versus
+	if v == nil || len(v.Wins) < 2 {
+		return
+	}
+	score := fmt.Sprintf("Rodada %d   %s %d x %d %s   (vence quem fizer %d)", v.Round, teamNames[1], v.Wins[0], v.Wins[1], teamNames[2], v.Target)
+	if v.FreeForAll {
+		leader := 0
+		for t := range v.Wins {
+			if v.Wins[t] > v.Wins[leader] {
+				leader = t
+			}
+		}
+		score = fmt.Sprintf("Rodada %d   Líder: %s com %d   (vence quem fizer %d)", v.Round, g.sideName(leader+1), v.Wins[leader], v.Target)
+	}
+	ebitenutil.DebugPrintAt(screen, score, screenWidth/2-150, 16)
+
+	msg := ""
+	switch {
+	case g.state.GameOver:
+	case v.Waiting:
+		msg = "Aguardando adversários..."
+	case v.Break > 0 && v.RoundWinner == 0:
+		msg = fmt.Sprintf("Empate! Próxima rodada em %d s", int(math.Ceil(v.Break)))
+	case v.Break > 0:
+		msg = fmt.Sprintf("%s venceu a rodada! Próxima rodada em %d s", g.sideName(v.RoundWinner), int(math.Ceil(v.Break)))
+	}
+	if msg != "" {
+		ebitenutil.DebugPrintAt(screen, msg, screenWidth/2-150, screenHeight/2-60)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+)
+
+// Weather is the level's weather, as sent by the server. Wind is signed:
+// positive blows to the right.
+type Weather struct {
+	Kind string  `json:"kind"`
+	Wind float64 `json:"wind"`
+}
+
+var weatherNames = map[string]string{
+	"clear": "limpo",
+	"rain":  "chuva",
+	"wind":  "vento",
+	"snow":  "neve",
+	"fog":   "neblina",
+}
+
+const (
+	maxParticles = 600
+	fogRadius    = 200.0
+)
+
+var fogColor = color.RGBA{R: 165, G: 170, B: 180, A: 230}
+
+// particle is a raindrop, snowflake or gust streak. They are purely
+// cosmetic, so each client rolls its own.
+type particle struct {
+	x, y, vx, vy float64
+	phase        float64
+}
+
+var fogMask *ebiten.Image
+
+// updateWeather spawns particles for the current weather and moves the
+// ones already falling.
+func (g *Game) updateWeather(dt float64) {
+	w := g.state.Weather
+	spawn := func(n int, newParticle func() particle) {
+		for range n {
+			if len(g.particles) < maxParticles {
+				g.particles = append(g.particles, newParticle())
+			}
+		}
+	}
+	// Particles start a bit upwind so the edge of the screen stays covered.
+	upwind := func() float64 {
+		return rand.Float64()*(screenWidth+200) - 100 - w.Wind
+	}
+	switch w.Kind {
+	case "rain":
+		spawn(6, func() particle {
+			return particle{x: upwind(), y: -10, vx: w.Wind, vy: 600 + rand.Float64()*100}
+		})
+	case "snow":
+		spawn(2, func() particle {
+			return particle{x: upwind(), y: -5, vx: w.Wind, vy: 50 + rand.Float64()*40, phase: rand.Float64() * 2 * math.Pi}
+		})
+	case "wind":
+		if rand.Intn(3) == 0 {
+			x := -20.0
+			if w.Wind < 0 {
+				x = screenWidth + 20
+			}
+			spawn(1, func() particle {
+				return particle{x: x, y: rand.Float64() * groundY, vx: w.Wind * (3 + rand.Float64()*2)}
+			})
+		}
+	}
+
+	alive := g.particles[:0]
+	for _, p := range g.particles {
+		p.x += p.vx * dt
+		if w.Kind == "snow" {
+			p.phase += dt * 2
+			p.x += 20 * math.Sin(p.phase) * dt
+		}
+		p.y += p.vy * dt
+		if p.y < groundY && p.x > -150 && p.x < screenWidth+150 {
+			alive = append(alive, p)
+		}
+	}
+	g.particles = alive
+}
+
+// drawWeather draws the particles and, in fog, hides whatever is far from
+// every player.
+func (g *Game) drawWeather(world *ebiten.Image) {
+	for _, p := range g.particles {
+		switch {
+		case p.vy > 300:
+			ebitenutil.DrawLine(world, p.x, p.y, p.x-p.vx*0.03, p.y-p.vy*0.03, color.RGBA{R: 150, G: 170, B: 230, A: 200})
+		case p.vy > 0:
+			ebitenutil.DrawRect(world, p.x, p.y, 3, 3, color.White)
+		default:
+			ebitenutil.DrawLine(world, p.x, p.y, p.x-p.vx*0.06, p.y, color.RGBA{R: 200, G: 200, B: 200, A: 120})
+		}
+	}
+
+	if g.state.Weather.Kind != "fog" {
+		return
+	}
+	if fogMask == nil {
+		fogMask = ebiten.NewImage(screenWidth, screenHeight)
+	}
+	a := float64(fogColor.A) / 255
+	fogMask.Fill(color.RGBA{R: uint8(float64(fogColor.R) * a), G: uint8(float64(fogColor.G) * a), B: uint8(float64(fogColor.B) * a), A: fogColor.A})
+	for _, p := range g.state.Players {
+		if !p.Out {
+			cutLight(fogMask, p.X, p.Y-playerHeight/2, fogRadius)
+		}
+	}
+	world.DrawImage(fogMask, nil)
+}
@@ -0,0 +1,276 @@
+// Command loadtest connects many simulated players to a game server, puts
+// them in rooms and plays for a while, then prints percentiles of how late
+// snapshots arrive against the server tick, snapshot jitter, websocket ping
+// round trips and bandwidth.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go-game/bot"
+)
+
+const maxRoomPlayers = 8
+
+type player struct {
+	client *bot.Client
+	before bot.Summary
+	after  bot.Summary
+
+	// done is closed once the client's Run loop returns. err is the first
+	// error that took the player out of the test; the Run and Play
+	// goroutines set it, so it is guarded by mu.
+	done chan struct{}
+	mu   sync.Mutex
+	err  error
+}
+
+// fail records err unless p already failed.
+func (p *player) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+func (p *player) failed() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+func main() {
+	addr := flag.String("addr", "localhost:3000", "endereço do servidor")
+	n := flag.Int("n", 8, "número de jogadores simulados")
+	roomCount := flag.Int("rooms", 2, "número de salas entre as quais os jogadores são divididos")
+	duration := flag.Duration("duration", 30*time.Second, "quanto tempo jogar depois que as partidas começam")
+	policy := flag.String("policy", "scripted", "como os jogadores jogam: scripted ou random")
+	tick := flag.Duration("tick", 50*time.Millisecond, "intervalo entre as decisões de cada jogador")
+	ping := flag.Duration("ping", time.Second, "intervalo entre os pings que medem o RTT")
+	serverTick := flag.Duration("servertick", 16*time.Millisecond, "intervalo de tick do servidor, base do atraso dos snapshots")
+	ramp := flag.Duration("ramp", 20*time.Millisecond, "pausa entre as conexões")
+	prefix := flag.String("prefix", "bot", "prefixo dos nomes dos jogadores")
+	mode := flag.String("mode", "coop", "modo de jogo das salas: coop, timeattack, endless ou versus")
+	delay := flag.Float64("delay", 0, "atraso em segundos da transmissão para espectadores")
+	friendlyFire := flag.Bool("friendlyfire", false, "no versus, balas acertam companheiros de time")
+	freeForAll := flag.Bool("ffa", false, "no versus, cada jogador é um time")
+	flag.Parse()
+
+	if *n < 1 || *roomCount < 1 || *roomCount > *n {
+		log.Fatal("use -n >= 1 e 1 <= -rooms <= -n")
+	}
+	if (*n+*roomCount-1) / *roomCount > maxRoomPlayers {
+		log.Fatalf("no máximo %d jogadores por sala: use mais salas", maxRoomPlayers)
+	}
+	if *policy != "scripted" && *policy != "random" {
+		log.Fatal("política desconhecida: ", *policy)
+	}
+
+	players := connect(*addr, *n, *prefix, *ping, *ramp)
+	groups := make([][]*player, *roomCount)
+	for i, p := range players {
+		if p.failed() == nil {
+			groups[i%*roomCount] = append(groups[i%*roomCount], p)
+		}
+	}
+
+	create := bot.Message{
+		Command:      "create",
+		Name:         "Teste de carga",
+		Mode:         *mode,
+		Delay:        *delay,
+		FriendlyFire: *friendlyFire,
+		FreeForAll:   *freeForAll,
+	}
+	var wg sync.WaitGroup
+	for _, g := range groups {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			setupRoom(g, create)
+		}()
+	}
+	wg.Wait()
+
+	log.Printf("Jogando por %s...", *duration)
+	stop := make(chan struct{})
+	for i, p := range players {
+		if p.failed() != nil {
+			continue
+		}
+		p.before = p.client.Stats.Summary()
+		var pol bot.Policy = bot.Scripted()
+		if *policy == "random" {
+			pol = bot.Random(rand.New(rand.NewPCG(uint64(i), 1)))
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.client.Play(pol, *tick, stop); err != nil {
+				p.fail(fmt.Errorf("desconectado: %w", err))
+			}
+		}()
+	}
+	time.Sleep(*duration)
+	close(stop)
+	wg.Wait()
+	for _, p := range players {
+		if p.client != nil {
+			p.after = p.client.Stats.Summary()
+			p.client.Close()
+			<-p.done
+		}
+	}
+
+	report(os.Stdout, players, *n, *roomCount, *duration, *policy, *serverTick)
+}
+
+// connect logs in and connects n players, one every ramp.
+func connect(addr string, n int, prefix string, ping, ramp time.Duration) []*player {
+	players := make([]*player, n)
+	for i := range players {
+		p := &player{}
+		players[i] = p
+		name := fmt.Sprintf("%s%03d", prefix, i)
+		s, err := bot.Authenticate(addr, name, "loadtest-"+name)
+		if err == nil {
+			p.client, err = bot.Dial(addr, s)
+		}
+		if err != nil {
+			p.client = nil
+			p.fail(fmt.Errorf("conexão: %w", err))
+			log.Printf("%s: %v", name, p.failed())
+			continue
+		}
+		p.done = make(chan struct{})
+		go func() {
+			defer close(p.done)
+			if err := p.client.Run(ping); err != nil {
+				p.fail(fmt.Errorf("desconectado: %w", err))
+			}
+		}()
+		time.Sleep(ramp)
+	}
+	log.Printf("%d jogadores conectados", n)
+	return players
+}
+
+// setupRoom has the first player of g create a room with the create
+// command, the others join it and mark themselves ready, and the host start
+// the match.
+func setupRoom(g []*player, create bot.Message) {
+	if len(g) == 0 {
+		return
+	}
+	fail := func(p *player, err error) {
+		p.fail(fmt.Errorf("sala: %w", err))
+		log.Printf("%s: sala: %v", p.client.ID, err)
+	}
+	host := g[0]
+	create.MaxPlayers = len(g)
+	host.client.Lobby(create)
+	room, err := host.client.WaitRoom(func(*bot.RoomInfo) bool { return true }, 5*time.Second)
+	if err != nil {
+		fail(host, err)
+		return
+	}
+	for _, p := range g[1:] {
+		p.client.Lobby(bot.Message{Command: "join", Room: room.Code})
+		if _, err := p.client.WaitRoom(func(r *bot.RoomInfo) bool { return r.Code == room.Code }, 5*time.Second); err != nil {
+			fail(p, err)
+			continue
+		}
+		p.client.Lobby(bot.Message{Command: "ready", Ready: true})
+	}
+	_, err = host.client.WaitRoom(func(r *bot.RoomInfo) bool {
+		ready := 0
+		for _, m := range r.Members {
+			if m.Ready || m.ID == r.Host {
+				ready++
+			}
+		}
+		return ready == len(g)
+	}, 10*time.Second)
+	if err != nil {
+		log.Printf("sala %s: nem todos ficaram prontos, iniciando assim mesmo", room.Code)
+	}
+	host.client.Lobby(bot.Message{Command: "start"})
+	for _, p := range g {
+		if _, err := p.client.WaitRoom(func(r *bot.RoomInfo) bool { return r.Started }, 5*time.Second); err != nil {
+			fail(p, err)
+		}
+	}
+}
+
+func report(w *os.File, players []*player, n, rooms int, d time.Duration, policy string, tick time.Duration) {
+	var rtt, intervals, jitter, late []time.Duration
+	var down, up, rates []float64
+	var totalIn, totalOut int64
+	failed := 0
+	for _, p := range players {
+		if err := p.failed(); err != nil {
+			failed++
+			fmt.Fprintf(w, "  %v\n", err)
+		}
+		if p.client == nil {
+			continue
+		}
+		s := p.after.Since(p.before)
+		secs := s.Elapsed.Seconds()
+		if secs <= 0 {
+			continue
+		}
+		rtt = append(rtt, s.RTT...)
+		late = append(late, bot.Lateness(s.Intervals, tick)...)
+		intervals = append(intervals, s.Intervals...)
+		jitter = append(jitter, s.Jitter...)
+		down = append(down, float64(s.Received)/1024/secs)
+		up = append(up, float64(s.Sent)/1024/secs)
+		rates = append(rates, float64(s.Snapshots)/secs)
+		totalIn += s.Received
+		totalOut += s.Sent
+	}
+
+	fmt.Fprintf(w, "\n%d jogadores em %d salas por %s (política %s), %d com erro\n\n", n, rooms, d, policy, failed)
+	fmt.Fprintf(w, "%-22s %9s %9s %9s %9s\n", "", "p50", "p90", "p99", "máx")
+	row := func(name string, ds []time.Duration) {
+		fmt.Fprintf(w, "%-22s %9s %9s %9s %9s\n", name,
+			round(bot.Percentile(ds, 50)), round(bot.Percentile(ds, 90)), round(bot.Percentile(ds, 99)), round(bot.Percentile(ds, 100)))
+	}
+	row("Atraso vs tick", late)
+	row("Intervalo snapshots", intervals)
+	row("Jitter", jitter)
+	row("RTT do ping", rtt)
+	frow := func(name, unit string, vs []float64) {
+		fmt.Fprintf(w, "%-22s %9s %9s %9s %9s\n", name,
+			fmt.Sprintf("%.1f%s", percentile(vs, 50), unit), fmt.Sprintf("%.1f%s", percentile(vs, 90), unit),
+			fmt.Sprintf("%.1f%s", percentile(vs, 99), unit), fmt.Sprintf("%.1f%s", percentile(vs, 100), unit))
+	}
+	frow("Recebido/jogador", "KB/s", down)
+	frow("Enviado/jogador", "KB/s", up)
+	frow("Snapshots/jogador", "/s", rates)
+	fmt.Fprintf(w, "\nTotal: %.1f MB recebidos, %.1f MB enviados\n", float64(totalIn)/(1<<20), float64(totalOut)/(1<<20))
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
+
+// percentile is bot.Percentile for plain numbers. vs is sorted in place.
+func percentile(vs []float64, p float64) float64 {
+	if len(vs) == 0 {
+		return 0
+	}
+	sort.Float64s(vs)
+	i := int(math.Ceil(p/100*float64(len(vs)))) - 1
+	return vs[max(0, min(i, len(vs)-1))]
+}
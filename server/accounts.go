@@ -0,0 +1,301 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	fws "github.com/gofiber/websocket/v2"
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	sessionTTL        = 7 * 24 * time.Hour
+	minPasswordLength = 6
+	maxDisplayName    = 20
+
+	// reservationTimeout is how long requireSession holds an ID for a
+	// websocket handshake that never reaches wsHandler.
+	reservationTimeout = 10 * time.Second
+)
+
+var (
+	usernamePattern = regexp.MustCompile(`^[a-z0-9_]{3,16}$`)
+	secretKey       = []byte("sessionSecret")
+
+	errAccountExists   = errors.New("conta já existe")
+	errBadCredentials  = errors.New("usuário ou senha incorretos")
+	errInvalidToken    = errors.New("sessão inválida")
+	errSessionExpired  = errors.New("sessão expirada")
+	errAlreadyPlaying  = errors.New("conta já conectada")
+	errAccountsOffline = errors.New("contas desativadas")
+)
+
+// Account is a registered player. ID is the login name and doubles as the
+// player ID in game; Name is what other players see.
+type Account struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	PasswordHash []byte    `json:"passwordHash"`
+	Created      time.Time `json:"created"`
+}
+
+// accountStore keeps accounts in bbolt and signs session tokens with a secret
+// stored next to them, so sessions survive restarts.
+type accountStore struct {
+	db     *bolt.DB
+	secret []byte
+	// dummyHash is compared against on unknown user names so that they take
+	// as long to reject as wrong passwords.
+	dummyHash []byte
+}
+
+// accounts is nil when the server runs without a database; players then
+// connect with a bare ?id= as before.
+var accounts *accountStore
+
+func openAccounts(db *bolt.DB) (*accountStore, error) {
+	s := &accountStore{db: db}
+	err := db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(metaBucket)
+		if secret := meta.Get(secretKey); secret != nil {
+			s.secret = append([]byte(nil), secret...)
+			return nil
+		}
+		s.secret = make([]byte, 32)
+		if _, err := rand.Read(s.secret); err != nil {
+			return err
+		}
+		return meta.Put(secretKey, s.secret)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s.dummyHash, err = bcrypt.GenerateFromPassword(s.secret[:8], bcrypt.DefaultCost); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *accountStore) register(id, name, password string) (Account, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return Account{}, err
+	}
+	acc := Account{ID: id, Name: name, PasswordHash: hash, Created: time.Now()}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(accountsBucket)
+		if b.Get([]byte(id)) != nil {
+			return errAccountExists
+		}
+		data, err := json.Marshal(acc)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(id), data)
+	})
+	return acc, err
+}
+
+func (s *accountStore) get(id string) (Account, bool) {
+	var acc Account
+	found := false
+	s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(accountsBucket).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		found = json.Unmarshal(data, &acc) == nil
+		return nil
+	})
+	return acc, found
+}
+
+func (s *accountStore) login(id, password string) (Account, error) {
+	acc, ok := s.get(id)
+	if !ok {
+		bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+		return Account{}, errBadCredentials
+	}
+	if bcrypt.CompareHashAndPassword(acc.PasswordHash, []byte(password)) != nil {
+		return Account{}, errBadCredentials
+	}
+	return acc, nil
+}
+
+// Session tokens are "<id>.<expiry unix>.<signature>", signed with
+// HMAC-SHA256 and base64url encoded.
+func (s *accountStore) issueToken(id string, now time.Time) string {
+	payload := id + "." + strconv.FormatInt(now.Add(sessionTTL).Unix(), 10)
+	return payload + "." + s.sign(payload)
+}
+
+func (s *accountStore) verifyToken(token string, now time.Time) (string, error) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return "", errInvalidToken
+	}
+	payload, sig := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(s.sign(payload))) {
+		return "", errInvalidToken
+	}
+	id, expiry, ok := strings.Cut(payload, ".")
+	if !ok {
+		return "", errInvalidToken
+	}
+	exp, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return "", errInvalidToken
+	}
+	if now.Unix() > exp {
+		return "", errSessionExpired
+	}
+	return id, nil
+}
+
+func (s *accountStore) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Name     string `json:"name"`
+}
+
+type session struct {
+	Token string `json:"token"`
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+}
+
+func validateCredentials(c credentials) (credentials, error) {
+	c.Username = strings.ToLower(strings.TrimSpace(c.Username))
+	c.Name = strings.TrimSpace(c.Name)
+	if !usernamePattern.MatchString(c.Username) {
+		return c, fmt.Errorf("usuário deve ter de 3 a 16 letras minúsculas, dígitos ou _")
+	}
+	if len(c.Password) < minPasswordLength {
+		return c, fmt.Errorf("senha deve ter pelo menos %d caracteres", minPasswordLength)
+	}
+	if c.Name == "" {
+		c.Name = c.Username
+	}
+	if utf8.RuneCountInString(c.Name) > maxDisplayName {
+		return c, fmt.Errorf("nome de exibição deve ter até %d caracteres", maxDisplayName)
+	}
+	return c, nil
+}
+
+// registerHandler serves POST /register with {username, password, name}.
+func registerHandler(c *fiber.Ctx) error {
+	if accounts == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, errAccountsOffline.Error())
+	}
+	var cred credentials
+	if err := c.BodyParser(&cred); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "corpo inválido")
+	}
+	cred, err := validateCredentials(cred)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	acc, err := accounts.register(cred.Username, cred.Name, cred.Password)
+	if errors.Is(err, errAccountExists) {
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusCreated).JSON(session{Token: accounts.issueToken(acc.ID, time.Now()), ID: acc.ID, Name: acc.Name})
+}
+
+// loginHandler serves POST /login with {username, password}.
+func loginHandler(c *fiber.Ctx) error {
+	if accounts == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, errAccountsOffline.Error())
+	}
+	var cred credentials
+	if err := c.BodyParser(&cred); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "corpo inválido")
+	}
+	acc, err := accounts.login(strings.ToLower(strings.TrimSpace(cred.Username)), cred.Password)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+	return c.JSON(session{Token: accounts.issueToken(acc.ID, time.Now()), ID: acc.ID, Name: acc.Name})
+}
+
+// requireSession guards the /ws upgrade. With accounts enabled the request
+// must carry a valid ?token=; the player ID and display name it resolves to
+// are left in the request locals for wsHandler.
+func requireSession(c *fiber.Ctx) error {
+	id, name := c.Query("id"), ""
+	if accounts != nil {
+		var err error
+		if id, err = accounts.verifyToken(c.Query("token"), time.Now()); err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+		}
+		acc, ok := accounts.get(id)
+		if !ok {
+			return fiber.NewError(fiber.StatusUnauthorized, errInvalidToken.Error())
+		}
+		name = acc.Name
+	}
+	if id == "" {
+		id = c.Context().RemoteAddr().String()
+	}
+	if isBotID(id) {
+		return fiber.NewError(fiber.StatusBadRequest, "IDs começando com \""+botIDPrefix+"\" são reservados aos bots")
+	}
+	if name == "" {
+		name = id
+	}
+	if !fws.IsWebSocketUpgrade(c) {
+		return fiber.ErrUpgradeRequired
+	}
+	// The ID is reserved with a placeholder in the same critical section as
+	// the check, so two connections with one session can't both get past
+	// it; wsHandler then takes the entry over. A failed upgrade releases it
+	// right away, and one that never reaches wsHandler (the client went away
+	// before the handshake finished) after reservationTimeout.
+	res := &Client{ID: id}
+	clientsMutex.Lock()
+	if _, playing := clients[id]; playing {
+		clientsMutex.Unlock()
+		return fiber.NewError(fiber.StatusConflict, errAlreadyPlaying.Error())
+	}
+	clients[id] = res
+	clientsMutex.Unlock()
+	c.Locals("playerID", id)
+	c.Locals("playerName", name)
+	if err := c.Next(); err != nil {
+		releaseClient(id, res)
+		return err
+	}
+	time.AfterFunc(reservationTimeout, func() { releaseClient(id, res) })
+	return nil
+}
+
+// releaseClient frees id if it still belongs to c, so a connection that
+// lost the ID to a newer one does not remove it.
+func releaseClient(id string, c *Client) {
+	clientsMutex.Lock()
+	if cur, ok := clients[id]; ok && cur == c {
+		delete(clients, id)
+	}
+	clientsMutex.Unlock()
+}
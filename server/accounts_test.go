@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	fws "github.com/gofiber/websocket/v2"
+)
+
+// TestRequireSessionWithoutUpgrade checks that a plain request to /ws
+// doesn't leave its player ID reserved.
+func TestRequireSessionWithoutUpgrade(t *testing.T) {
+	app := fiber.New()
+	app.Get("/ws", requireSession, fws.New(wsHandler))
+	resp, err := app.Test(httptest.NewRequest("GET", "/ws?id=ana", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusUpgradeRequired {
+		t.Errorf("status = %d, want %d", resp.StatusCode, fiber.StatusUpgradeRequired)
+	}
+	clientsMutex.Lock()
+	_, reserved := clients["ana"]
+	clientsMutex.Unlock()
+	if reserved {
+		t.Error("ID still reserved after a request without upgrade")
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go-game/server/sim"
+)
+
+// Bot players are ordinary Players driven by the server. A room keeps up to
+// Bots of them while there are free slots, so a human joining takes a bot's
+// place and a human leaving brings it back.
+const (
+	botIDPrefix       = "bot-"
+	defaultDifficulty = "normal"
+)
+
+// botBrain is what a bot remembers between ticks.
+type botBrain struct {
+	think float64
+}
+
+func isBotID(id string) bool {
+	return strings.HasPrefix(id, botIDPrefix)
+}
+
+// balanceBots adds or removes bots so that r has min(Bots, free slots) of
+// them. Callers hold stateMutex; it does nothing before the match starts.
+func (r *Room) balanceBots() {
+	if !r.Started {
+		return
+	}
+	want := max(0, min(r.Bots, r.MaxPlayers-len(r.members)))
+	ids := r.botIDs()
+	for len(ids) > want {
+		id := ids[len(ids)-1]
+		ids = ids[:len(ids)-1]
+		delete(r.bots, id)
+		delete(r.state.Players, id)
+		r.record(replayEvent{Kind: "leave", ID: id})
+	}
+	for n := 1; len(ids) < want; n++ {
+		id := fmt.Sprintf("%s%d", botIDPrefix, n)
+		if _, taken := r.bots[id]; taken {
+			continue
+		}
+		p := r.state.AddPlayer(id, fmt.Sprintf("Bot %d", n), true)
+		r.bots[id] = &botBrain{}
+		ids = append(ids, id)
+		r.record(replayEvent{Kind: "join", ID: id, Name: p.Name, Bot: true})
+	}
+}
+
+func (r *Room) botIDs() []string {
+	ids := make([]string, 0, len(r.bots))
+	for id := range r.bots {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if len(ids[i]) != len(ids[j]) {
+			return len(ids[i]) < len(ids[j])
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+// updateBots lets every bot of r that is due think and act. Their commands
+// go through ApplyInput and into the replay like a human's. Callers hold
+// stateMutex with r in use.
+func (r *Room) updateBots(dt float64) {
+	diff := sim.BotDifficulties[r.Difficulty]
+	for _, id := range r.botIDs() {
+		b := r.bots[id]
+		p := r.state.Players[id]
+		if p == nil || p.Lives <= 0 {
+			continue
+		}
+		if b.think -= dt; b.think > 0 {
+			continue
+		}
+		b.think = diff.Reaction * (0.75 + 0.5*r.botRng.Float64())
+		for _, m := range r.state.BotDecide(p, diff, r.botRng) {
+			m.PlayerID = id
+			if r.state.ApplyInput(p, m) {
+				r.record(replayEvent{Kind: "input", Input: &m})
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-game/server/sim"
+
+	"github.com/gofiber/fiber/v2"
+	fws "github.com/gofiber/websocket/v2"
+)
+
+// The gym endpoints serve sim.Env environments. They don't touch stateMutex:
+// every environment has its own GameState and its own lock, so environments
+// step in parallel with each other and with the rooms.
+const (
+	gymMaxEnvs     = 256
+	gymIdleTimeout = 10 * time.Minute
+)
+
+// gymEnv is an environment created over HTTP. mu keeps two requests from
+// using it at once. lastUsed is when the last one came in, in Unix
+// nanoseconds; it is atomic so reapGymEnvs can read it without taking mu.
+type gymEnv struct {
+	mu       sync.Mutex
+	env      *sim.Env
+	lastUsed atomic.Int64
+}
+
+func newGymEnv(repeat, maxSteps int) *gymEnv {
+	e := &gymEnv{env: sim.NewEnv(repeat, maxSteps)}
+	e.lastUsed.Store(time.Now().UnixNano())
+	return e
+}
+
+func (e *gymEnv) reset(seed uint64) sim.StepResult {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lastUsed.Store(time.Now().UnixNano())
+	return e.env.Reset(seed)
+}
+
+func (e *gymEnv) step(a sim.Action) sim.StepResult {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lastUsed.Store(time.Now().UnixNano())
+	return e.env.Step(a)
+}
+
+// gymEnvs holds the environments created over HTTP. gymEnvsMutex only
+// guards the map; it is never held while an environment steps.
+var (
+	gymEnvs      = map[string]*gymEnv{}
+	gymEnvsMutex sync.Mutex
+)
+
+func newEnvID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type gymCreateRequest struct {
+	Repeat   int `json:"repeat"`
+	MaxSteps int `json:"maxSteps"`
+}
+
+type gymCreateResponse struct {
+	ID      string `json:"id"`
+	ObsSize int    `json:"obsSize"`
+}
+
+type gymRequest struct {
+	Op     string     `json:"op"`
+	Seed   uint64     `json:"seed"`
+	Action sim.Action `json:"action"`
+}
+
+func addEnv(e *gymEnv) (string, bool) {
+	gymEnvsMutex.Lock()
+	defer gymEnvsMutex.Unlock()
+	if len(gymEnvs) >= gymMaxEnvs {
+		return "", false
+	}
+	id := newEnvID()
+	gymEnvs[id] = e
+	return id, true
+}
+
+// gymCreateHandler serves POST /gym/envs {repeat, maxSteps}.
+func gymCreateHandler(c *fiber.Ctx) error {
+	var req gymCreateRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "corpo inválido")
+		}
+	}
+	id, ok := addEnv(newGymEnv(req.Repeat, req.MaxSteps))
+	if !ok {
+		return fiber.NewError(fiber.StatusTooManyRequests, "ambientes demais")
+	}
+	return c.Status(fiber.StatusCreated).JSON(gymCreateResponse{ID: id, ObsSize: sim.ObsSize})
+}
+
+func envFor(c *fiber.Ctx) (*gymEnv, error) {
+	gymEnvsMutex.Lock()
+	defer gymEnvsMutex.Unlock()
+	e, ok := gymEnvs[c.Params("id")]
+	if !ok {
+		return nil, fiber.NewError(fiber.StatusNotFound, "ambiente não encontrado")
+	}
+	return e, nil
+}
+
+// gymResetHandler serves POST /gym/envs/:id/reset {seed}.
+func gymResetHandler(c *fiber.Ctx) error {
+	e, err := envFor(c)
+	if err != nil {
+		return err
+	}
+	var req gymRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "corpo inválido")
+		}
+	}
+	return c.JSON(e.reset(req.Seed))
+}
+
+// gymStepHandler serves POST /gym/envs/:id/step {action}.
+func gymStepHandler(c *fiber.Ctx) error {
+	e, err := envFor(c)
+	if err != nil {
+		return err
+	}
+	var req gymRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "corpo inválido")
+	}
+	return c.JSON(e.step(req.Action))
+}
+
+// gymDeleteHandler serves DELETE /gym/envs/:id.
+func gymDeleteHandler(c *fiber.Ctx) error {
+	gymEnvsMutex.Lock()
+	delete(gymEnvs, c.Params("id"))
+	gymEnvsMutex.Unlock()
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// gymWSHandler serves /gym/ws. Each connection gets its own environment,
+// created with the repeat and maxSteps query parameters; messages are
+// {"op":"reset","seed":N} or {"op":"step","action":{...}} and each one is
+// answered with a StepResult. Only the connection's goroutine uses its
+// environment, so it needs no lock.
+func gymWSHandler(c *fws.Conn) {
+	e := sim.NewEnv(atoiOr(c.Query("repeat"), 0), atoiOr(c.Query("maxSteps"), 0))
+	for {
+		_, msg, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req gymRequest
+		if err := json.Unmarshal(msg, &req); err != nil {
+			c.WriteJSON(errorMessage{Type: "error", Error: "mensagem inválida"})
+			continue
+		}
+		var res sim.StepResult
+		switch req.Op {
+		case "reset":
+			res = e.Reset(req.Seed)
+		case "step":
+			res = e.Step(req.Action)
+		default:
+			c.WriteJSON(errorMessage{Type: "error", Error: "operação desconhecida: " + req.Op})
+			continue
+		}
+		if err := c.WriteJSON(res); err != nil {
+			return
+		}
+	}
+}
+
+func atoiOr(s string, def int) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// reapGymEnvs drops environments nobody has used for gymIdleTimeout. A
+// request already holding a dropped environment still finishes with it.
+func reapGymEnvs() {
+	for range time.Tick(time.Minute) {
+		gymEnvsMutex.Lock()
+		for id, e := range gymEnvs {
+			if time.Since(time.Unix(0, e.lastUsed.Load())) > gymIdleTimeout {
+				delete(gymEnvs, id)
+				log.Println("Ambiente de treino ocioso removido:", id)
+			}
+		}
+		gymEnvsMutex.Unlock()
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	"go-game/server/sim"
+
+	"github.com/gofiber/fiber/v2"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	defaultTopN = 10
+	maxTopN     = 100
+)
+
+// RunRecord is one player's result in a finished run. Name is the display
+// name the player had at the time. Runs stored before modes were recorded
+// have no Mode and count as co-op.
+type RunRecord struct {
+	Mode     string    `json:"mode"`
+	Player   string    `json:"player"`
+	Name     string    `json:"name"`
+	Score    int       `json:"score"`
+	Level    int       `json:"level"`
+	Duration float64   `json:"duration"`
+	Time     time.Time `json:"time"`
+}
+
+// leaderboard stores finished runs in a bbolt file, keyed by insertion order.
+type leaderboard struct {
+	db *bolt.DB
+}
+
+// scores is nil when the server runs without a database.
+var scores *leaderboard
+
+func (l *leaderboard) record(runs []RunRecord) error {
+	return l.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(runsBucket)
+		for _, r := range runs {
+			data, err := json.Marshal(r)
+			if err != nil {
+				return err
+			}
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			key := binary.BigEndian.AppendUint64(nil, seq)
+			if err := b.Put(key, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// top returns the n best runs of mode finished at or after since, best
+// first. Ties go to the run that reached the higher level, then to the
+// earlier one.
+func (l *leaderboard) top(n int, since time.Time, mode string) ([]RunRecord, error) {
+	runs := []RunRecord{}
+	err := l.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(runsBucket).ForEach(func(_, v []byte) error {
+			var r RunRecord
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			if r.Mode == "" {
+				r.Mode = sim.ModeCoop
+			}
+			if r.Mode == mode && !r.Time.Before(since) {
+				runs = append(runs, r)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		a, b := runs[i], runs[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Level != b.Level {
+			return a.Level > b.Level
+		}
+		return a.Time.Before(b.Time)
+	})
+	return runs[:min(n, len(runs))], nil
+}
+
+// finishedRuns turns the current run into one record per human player who
+// scored. Each mode but versus has its own board; endless runs never end,
+// so they are recorded when the players restart.
+func finishedRuns(g *sim.GameState) []RunRecord {
+	mode := g.Mode
+	if !rankedMode(mode) {
+		return nil
+	}
+	now := time.Now()
+	var runs []RunRecord
+	for id, p := range g.Players {
+		if p.Stats.Score == 0 || p.Bot {
+			continue
+		}
+		runs = append(runs, RunRecord{
+			Mode:     mode,
+			Player:   id,
+			Name:     p.Name,
+			Score:    p.Stats.Score,
+			Level:    g.Level,
+			Duration: g.Elapsed(),
+			Time:     now,
+		})
+	}
+	return runs
+}
+
+// rankedMode reports whether mode has a leaderboard.
+func rankedMode(mode string) bool {
+	return mode == sim.ModeCoop || mode == sim.ModeTimeAttack || mode == sim.ModeEndless
+}
+
+// saveRuns stores runs when the leaderboard is enabled. It must be called
+// without holding stateMutex since it waits for the disk.
+func saveRuns(runs []RunRecord) {
+	if scores == nil || len(runs) == 0 {
+		return
+	}
+	if err := scores.record(runs); err != nil {
+		log.Println("Erro ao gravar recordes:", err)
+	}
+}
+
+// leaderboardHandler serves
+// GET /leaderboard?period=all|daily|weekly&mode=coop|timeattack|endless&n=10.
+// Daily and weekly boards cover the last 24 hours and the last 7 days.
+func leaderboardHandler(c *fiber.Ctx) error {
+	if scores == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "placar desativado")
+	}
+	period := c.Query("period", "all")
+	var since time.Time
+	switch period {
+	case "all":
+	case "daily":
+		since = time.Now().Add(-24 * time.Hour)
+	case "weekly":
+		since = time.Now().Add(-7 * 24 * time.Hour)
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "período inválido: use all, daily ou weekly")
+	}
+	mode := c.Query("mode", sim.ModeCoop)
+	if !rankedMode(mode) {
+		return fiber.NewError(fiber.StatusBadRequest, "modo inválido: use coop, timeattack ou endless")
+	}
+	n := max(1, min(c.QueryInt("n", defaultTopN), maxTopN))
+	runs, err := scores.top(n, since, mode)
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{"period": period, "mode": mode, "entries": runs})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"go-game/server/sim"
+)
+
+const (
+	quickMatchSize = 4
+	quickMatchWait = 10 * time.Second
+)
+
+type queuedClient struct {
+	client *Client
+	since  time.Time
+}
+
+// quickQueue holds the players waiting for a quick match, oldest first. It is
+// guarded by stateMutex.
+var quickQueue []queuedClient
+
+func enqueue(c *Client) {
+	if slices.ContainsFunc(quickQueue, func(q queuedClient) bool { return q.client == c }) {
+		return
+	}
+	quickQueue = append(quickQueue, queuedClient{client: c, since: time.Now()})
+	c.sendJSON(noticeMessage{Type: "queued"})
+}
+
+func leaveQueue(c *Client) {
+	quickQueue = slices.DeleteFunc(quickQueue, func(q queuedClient) bool { return q.client == c })
+}
+
+// matchmaker groups queued players once a second.
+func matchmaker() {
+	for now := range time.Tick(time.Second) {
+		stateMutex.Lock()
+		matchQueued(now)
+		stateMutex.Unlock()
+	}
+}
+
+// matchQueued first drops waiting players into public matches that still
+// have room, then opens a new room once quickMatchSize players are waiting or
+// the oldest has waited quickMatchWait.
+func matchQueued(now time.Time) {
+	waiting := quickQueue
+	quickQueue = nil
+	for _, q := range waiting {
+		if r := openPublicMatch(); r != nil {
+			r.join(q.client)
+		} else {
+			quickQueue = append(quickQueue, q)
+		}
+	}
+
+	for len(quickQueue) >= quickMatchSize || len(quickQueue) > 0 && now.Sub(quickQueue[0].since) >= quickMatchWait {
+		n := min(len(quickQueue), quickMatchSize)
+		group := quickQueue[:n]
+		quickQueue = quickQueue[n:]
+		r := newRoom("Partida rápida", true, quickMatchSize, sim.ModeCoop)
+		for _, q := range group {
+			r.join(q.client)
+		}
+		r.start()
+	}
+}
+
+// openPublicMatch picks the fullest public co-op match that is running and
+// has a free slot, or nil.
+func openPublicMatch() *Room {
+	var open []*Room
+	for _, r := range rooms {
+		if r.Public && r.Mode == sim.ModeCoop && r.Started && !r.full() && !r.state.GameOver {
+			open = append(open, r)
+		}
+	}
+	sort.Slice(open, func(i, j int) bool {
+		if len(open[i].members) != len(open[j].members) {
+			return len(open[i].members) > len(open[j].members)
+		}
+		return open[i].Code < open[j].Code
+	})
+	if len(open) == 0 {
+		return nil
+	}
+	return open[0]
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go-game/server/sim"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// replayVersion must be bumped whenever the simulation changes, so that
+// replays recorded under older rules are turned away instead of failing
+// verification.
+const (
+	replayVersion    = 7
+	replayTickRate   = 60
+	replayFrameEvery = 2
+	replayExt        = ".replay"
+)
+
+// replaysDir is where finished runs are recorded; empty disables recording.
+var replaysDir string
+
+// A replay file is gzip-compressed JSON lines: a replayHeader followed by
+// replayEvents in tick order. The seed, the level scripts and the inputs are
+// enough to re-simulate the run, and every tick carries a hash of the state
+// so a re-simulation can be checked (see -verify). The frames are the
+// snapshots players saw, so the client can play a run back without
+// simulating it.
+type replayHeader struct {
+	Version  int            `json:"version"`
+	Room     string         `json:"room"`
+	Mode     string         `json:"mode"`
+	Seed     uint64         `json:"seed"`
+	Level    int            `json:"level"`
+	TickRate int            `json:"tickRate"`
+	Started  time.Time      `json:"started"`
+	Players  []replayPlayer `json:"players"`
+	Levels   []sim.LevelDef `json:"levels"`
+
+	// Projectiles is the projectile table the run used, which -projectiles
+	// may have changed from the embedded one.
+	Projectiles map[string]sim.ProjectileDef `json:"projectiles"`
+
+	// The versus rules are stored inline, as rounds, friendlyFire and
+	// freeForAll.
+	sim.Rules
+}
+
+type replayPlayer struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Dir  int    `json:"dir,omitempty"`
+	Bot  bool   `json:"bot,omitempty"`
+}
+
+// replayEvent is one line after the header. Tick counts the steps simulated
+// before the event: inputs, joins, leaves and level changes at tick N were
+// applied before step N+1, and a hash or frame at tick N is the state after
+// step N.
+type replayEvent struct {
+	Tick  int             `json:"tick"`
+	Kind  string          `json:"kind"`
+	Input *sim.Input      `json:"input,omitempty"`
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Bot   bool            `json:"bot,omitempty"`
+	Level int             `json:"level,omitempty"`
+	Hash  uint64          `json:"hash,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+type replayRecorder struct {
+	path string
+	file *os.File
+	gz   *gzip.Writer
+	buf  *bufio.Writer
+	enc  *json.Encoder
+	tick int
+}
+
+// startRecording begins a replay of the run r has just started, closing the
+// previous one. Callers hold stateMutex.
+func (r *Room) startRecording() {
+	r.stopRecording()
+	if replaysDir == "" {
+		return
+	}
+	if err := os.MkdirAll(replaysDir, 0o755); err != nil {
+		log.Println("Erro ao criar o diretório de replays:", err)
+		return
+	}
+	name := fmt.Sprintf("%s-%s%s", time.Now().Format("20060102-150405"), r.Code, replayExt)
+	path := filepath.Join(replaysDir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		log.Println("Erro ao criar replay:", err)
+		return
+	}
+	gz := gzip.NewWriter(f)
+	buf := bufio.NewWriter(gz)
+	rec := &replayRecorder{path: path, file: f, gz: gz, buf: buf, enc: json.NewEncoder(buf)}
+
+	h := replayHeader{
+		Version:  replayVersion,
+		Room:     r.Code,
+		Mode:     r.Mode,
+		Rules:    r.state.Rules,
+		Seed:     r.state.Seed,
+		Level:    r.state.Level,
+		TickRate: replayTickRate,
+		Started:  time.Now(),
+		Levels:   r.state.Levels(),
+
+		Projectiles: r.state.Projectiles(),
+	}
+	for _, p := range r.state.SortedPlayers() {
+		h.Players = append(h.Players, replayPlayer{ID: p.ID, Name: p.Name, Dir: p.Dir(), Bot: p.Bot})
+	}
+	if err := rec.enc.Encode(h); err != nil {
+		log.Println("Erro ao gravar replay:", err)
+		rec.close()
+		return
+	}
+	r.rec = rec
+}
+
+// record appends ev to the running replay, stamped with the current tick.
+func (r *Room) record(ev replayEvent) {
+	if r.rec == nil {
+		return
+	}
+	ev.Tick = r.rec.tick
+	if err := r.rec.enc.Encode(ev); err != nil {
+		log.Println("Erro ao gravar replay:", err)
+		r.rec.close()
+		r.rec = nil
+	}
+}
+
+// recordTick counts a simulated step and records the hash of its snapshot,
+// along with the snapshot itself every replayFrameEvery ticks. Frames that
+// must not be skipped, like the last one, pass force.
+func (r *Room) recordTick(snapshot []byte, force bool) {
+	if r.rec == nil {
+		return
+	}
+	r.rec.tick++
+	ev := replayEvent{Kind: "hash", Hash: stateHash(snapshot)}
+	if force || r.rec.tick%replayFrameEvery == 0 {
+		ev.Kind, ev.Data = "frame", snapshot
+	}
+	r.record(ev)
+}
+
+func stateHash(snapshot []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(snapshot)
+	return h.Sum64()
+}
+
+func (r *Room) stopRecording() {
+	if r.rec == nil {
+		return
+	}
+	r.rec.close()
+	log.Println("Replay gravado:", r.rec.path)
+	r.rec = nil
+}
+
+func (rec *replayRecorder) close() {
+	if err := rec.buf.Flush(); err != nil {
+		log.Println("Erro ao gravar replay:", err)
+	}
+	if err := rec.gz.Close(); err != nil {
+		log.Println("Erro ao gravar replay:", err)
+	}
+	rec.file.Close()
+}
+
+// readReplay loads a whole replay file. A file cut short by a server that
+// stopped mid-run yields the events written up to that point.
+func readReplay(path string) (replayHeader, []replayEvent, error) {
+	var h replayHeader
+	f, err := os.Open(path)
+	if err != nil {
+		return h, nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return h, nil, fmt.Errorf("%s: não é um replay: %w", path, err)
+	}
+	dec := json.NewDecoder(gz)
+	if err := dec.Decode(&h); err != nil {
+		return h, nil, fmt.Errorf("%s: cabeçalho inválido: %w", path, err)
+	}
+	if h.Version != replayVersion {
+		return h, nil, fmt.Errorf("%s: versão de replay %d não suportada (esperada %d)", path, h.Version, replayVersion)
+	}
+	var events []replayEvent
+	for dec.More() {
+		var ev replayEvent
+		if err := dec.Decode(&ev); err != nil {
+			break
+		}
+		events = append(events, ev)
+	}
+	return h, events, nil
+}
+
+// replaysHandler serves GET /replays: the recorded replays, newest first.
+func replaysHandler(c *fiber.Ctx) error {
+	if replaysDir == "" {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "replays desativados")
+	}
+	names, _ := filepath.Glob(filepath.Join(replaysDir, "*"+replayExt))
+	list := []string{}
+	for _, name := range names {
+		list = append(list, filepath.Base(name))
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(list)))
+	return c.JSON(list)
+}
+
+// replayFileHandler serves GET /replays/:name, the replay file itself.
+func replayFileHandler(c *fiber.Ctx) error {
+	name := filepath.Base(c.Params("name"))
+	if replaysDir == "" || !strings.HasSuffix(name, replayExt) {
+		return fiber.ErrNotFound
+	}
+	path := filepath.Join(replaysDir, name)
+	if _, err := os.Stat(path); err != nil {
+		return fiber.ErrNotFound
+	}
+	return c.Download(path)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"testing"
+
+	"go-game/server/sim"
+)
+
+// TestVerifyCustomProjectiles records a run with a projectile table other
+// than the loaded one; verification must use the table from the header.
+func TestVerifyCustomProjectiles(t *testing.T) {
+	replaysDir = t.TempDir()
+	defer func() { replaysDir = "" }()
+
+	custom := maps.Clone(sim.Projectiles())
+	enemy := custom["enemy"]
+	enemy.Speed *= 2
+	custom["enemy"] = enemy
+
+	stateMutex.Lock()
+	r := newRoom("replay", false, 1, sim.ModeCoop)
+	delete(rooms, r.Code)
+	stateMutex.Unlock()
+	r.state.Players["a"] = r.state.NewPlayer("a", "a")
+	r.state.NewRun(1, sim.Scripts(), custom)
+	r.startRecording()
+	path := r.rec.path
+
+	plain := sim.NewGameState()
+	plain.Players["a"] = plain.NewPlayer("a", "a")
+	plain.NewRun(1, sim.Scripts(), sim.Projectiles())
+	for range 900 {
+		r.state.Step(sim.TickDT)
+		plain.Step(sim.TickDT)
+		snapshot, err := json.Marshal(r.state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.recordTick(snapshot, false)
+	}
+	r.stopRecording()
+
+	if a, b := mustMarshal(t, r.state), mustMarshal(t, plain); string(a) == string(b) {
+		t.Fatal("the custom table didn't change the run")
+	}
+	if _, err := verifyReplay(path); err != nil {
+		t.Error(err)
+	}
+}
+
+func mustMarshal(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
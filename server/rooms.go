@@ -0,0 +1,599 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"time"
+
+	"go-game/server/sim"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultMaxPlayers = 4
+	maxRoomPlayers    = 8
+	roomCodeLength    = 5
+	roomCodeAlphabet  = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	maxRoomName       = 24
+	maxSpectators     = 32
+	maxSpectatorDelay = 30.0
+)
+
+// Room is one match and the lobby around it. Players gather in the lobby,
+// mark themselves ready and the host starts the match; afterwards more
+// players may drop in until the room is full. Up to Bots free slots are
+// filled with server-controlled players. Spectators watch without an avatar
+// and don't take a slot; with a Delay they see the match that many seconds
+// late. Everything in a Room is guarded by stateMutex.
+type Room struct {
+	Code       string
+	Name       string
+	Public     bool
+	MaxPlayers int
+	Mode       string
+	Host       string
+	StartLevel int
+	Started    bool
+	Delay      float64
+	Bots       int
+	Difficulty string
+	Rounds     int
+
+	// FriendlyFire and FreeForAll are versus rules: bullets hit teammates,
+	// and every player is a team of their own.
+	FriendlyFire bool
+	FreeForAll   bool
+
+	state      *sim.GameState
+	members    map[string]*Client
+	spectators map[string]*Client
+	ready      map[string]bool
+	feed       []delayedFrame
+	closed     bool
+	rec        *replayRecorder
+	bots       map[string]*botBrain
+	botRng     *rand.Rand
+}
+
+// delayedFrame is a snapshot waiting to be shown to spectators.
+type delayedFrame struct {
+	at   time.Time
+	data []byte
+}
+
+// rooms holds every open room by code.
+var rooms = map[string]*Room{}
+
+// RoomInfo describes a room to clients. Members is only filled in for the
+// players inside the room.
+type RoomInfo struct {
+	Code         string       `json:"code"`
+	Name         string       `json:"name"`
+	Public       bool         `json:"public"`
+	MaxPlayers   int          `json:"maxPlayers"`
+	Players      int          `json:"players"`
+	Mode         string       `json:"mode"`
+	Host         string       `json:"host"`
+	Level        int          `json:"level"`
+	Started      bool         `json:"started"`
+	Spectators   int          `json:"spectators"`
+	Delay        float64      `json:"delay,omitempty"`
+	Bots         int          `json:"bots"`
+	Difficulty   string       `json:"difficulty"`
+	Rounds       int          `json:"rounds,omitempty"`
+	FriendlyFire bool         `json:"friendlyFire,omitempty"`
+	FreeForAll   bool         `json:"freeForAll,omitempty"`
+	Members      []MemberInfo `json:"members,omitempty"`
+}
+
+type MemberInfo struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Ready     bool   `json:"ready"`
+	Spectator bool   `json:"spectator,omitempty"`
+}
+
+type roomsMessage struct {
+	Type  string     `json:"type"`
+	Rooms []RoomInfo `json:"rooms"`
+}
+
+type roomMessage struct {
+	Type string   `json:"type"`
+	Room RoomInfo `json:"room"`
+}
+
+// noticeMessage tells a client something happened to them: "left" a room
+// (with Reason "kicked" when the host removed them) or "queued" for a quick
+// match.
+type noticeMessage struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type errorMessage struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+func newRoomCode() string {
+	for {
+		b := make([]byte, roomCodeLength)
+		for i := range b {
+			b[i] = roomCodeAlphabet[rand.IntN(len(roomCodeAlphabet))]
+		}
+		if _, taken := rooms[string(b)]; !taken {
+			return string(b)
+		}
+	}
+}
+
+func newRoom(name string, public bool, maxPlayers int, mode string) *Room {
+	r := &Room{
+		Code:       newRoomCode(),
+		Name:       name,
+		Public:     public,
+		MaxPlayers: maxPlayers,
+		Mode:       mode,
+		StartLevel: 1,
+		Difficulty: defaultDifficulty,
+		Rounds:     sim.DefaultRounds,
+		state:      sim.NewGameState(),
+		members:    map[string]*Client{},
+		spectators: map[string]*Client{},
+		ready:      map[string]bool{},
+		bots:       map[string]*botBrain{},
+		botRng:     rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+	}
+	rooms[r.Code] = r
+	log.Printf("Sala %s criada (%s)", r.Code, r.Name)
+	return r
+}
+
+func (r *Room) full() bool {
+	return len(r.members) >= r.MaxPlayers
+}
+
+func (r *Room) info(withMembers bool) RoomInfo {
+	info := RoomInfo{
+		Code:       r.Code,
+		Name:       r.Name,
+		Public:     r.Public,
+		MaxPlayers: r.MaxPlayers,
+		Players:    len(r.members),
+		Mode:       r.Mode,
+		Host:       r.Host,
+		Level:      r.StartLevel,
+		Started:    r.Started,
+		Spectators: len(r.spectators),
+		Delay:      r.Delay,
+		Bots:       r.Bots,
+		Difficulty: r.Difficulty,
+	}
+	if r.Mode == sim.ModeVersus {
+		info.Rounds = r.Rounds
+		info.FriendlyFire, info.FreeForAll = r.FriendlyFire, r.FreeForAll
+	}
+	if r.Started {
+		info.Level = r.state.Level
+	}
+	if withMembers {
+		for _, id := range r.memberIDs() {
+			info.Members = append(info.Members, MemberInfo{ID: id, Name: r.members[id].Name, Ready: r.ready[id]})
+		}
+		for _, id := range sortedIDs(r.spectators) {
+			info.Members = append(info.Members, MemberInfo{ID: id, Name: r.spectators[id].Name, Spectator: true})
+		}
+	}
+	return info
+}
+
+func (r *Room) memberIDs() []string {
+	return sortedIDs(r.members)
+}
+
+func sortedIDs(clients map[string]*Client) []string {
+	ids := make([]string, 0, len(clients))
+	for id := range clients {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// broadcast sends v to everyone in the room, spectators included.
+func (r *Room) broadcast(v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Println("Erro ao serializar o estado:", err)
+		return
+	}
+	for _, c := range r.members {
+		c.send(data)
+	}
+	for _, c := range r.spectators {
+		c.send(data)
+	}
+}
+
+// broadcastDelayed sends v to the players right away and to the spectators
+// once it is r.Delay seconds old. Snapshots and the scoreboard go through
+// here so spectators see the match end when it ends for them.
+func (r *Room) broadcastDelayed(v any, now time.Time) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Println("Erro ao serializar o estado:", err)
+		return nil
+	}
+	r.sendDelayed(data, now)
+	return data
+}
+
+func (r *Room) sendDelayed(data []byte, now time.Time) {
+	for _, c := range r.members {
+		c.send(data)
+	}
+	if r.Delay <= 0 {
+		for _, c := range r.spectators {
+			c.send(data)
+		}
+		return
+	}
+	r.feed = append(r.feed, delayedFrame{at: now, data: data})
+	cutoff := now.Add(-time.Duration(r.Delay * float64(time.Second)))
+	sent := 0
+	for _, f := range r.feed {
+		if f.at.After(cutoff) {
+			break
+		}
+		for _, c := range r.spectators {
+			c.send(f.data)
+		}
+		sent++
+	}
+	r.feed = r.feed[sent:]
+}
+
+func (r *Room) announce() {
+	r.broadcast(roomMessage{Type: "room", Room: r.info(true)})
+}
+
+// join adds c to r, dropping them straight into the match when it is
+// already running.
+func (r *Room) join(c *Client) {
+	if c.room != nil {
+		c.room.leave(c, "")
+	}
+	leaveQueue(c)
+	c.spectator = false
+	if r.Host == "" {
+		r.Host = c.ID
+	}
+	r.members[c.ID] = c
+	c.room = r
+	if r.Started {
+		r.state.AddPlayer(c.ID, c.Name, false)
+		r.record(replayEvent{Kind: "join", ID: c.ID, Name: c.Name})
+		r.balanceBots()
+	}
+	r.announce()
+}
+
+// watch adds c to r as a spectator.
+func (r *Room) watch(c *Client) {
+	if c.room != nil {
+		c.room.leave(c, "")
+	}
+	leaveQueue(c)
+	c.spectator = true
+	r.spectators[c.ID] = c
+	c.room = r
+	r.announce()
+}
+
+// leave removes c from r. A non-empty reason is sent to c (for example
+// "kicked"). The host role passes to the next player, and rooms close once
+// the last player leaves, sending their spectators back to the lobby.
+func (r *Room) leave(c *Client, reason string) {
+	c.room = nil
+	c.sendJSON(noticeMessage{Type: "left", Reason: reason})
+	if c.spectator {
+		c.spectator = false
+		delete(r.spectators, c.ID)
+		r.announce()
+		return
+	}
+	delete(r.members, c.ID)
+	delete(r.ready, c.ID)
+	if _, ok := r.state.Players[c.ID]; ok {
+		delete(r.state.Players, c.ID)
+		r.record(replayEvent{Kind: "leave", ID: c.ID})
+	}
+
+	if len(r.members) == 0 {
+		r.stopRecording()
+		r.closed = true
+		delete(rooms, r.Code)
+		for _, s := range r.spectators {
+			s.room, s.spectator = nil, false
+			s.sendJSON(noticeMessage{Type: "left", Reason: "closed"})
+		}
+		log.Printf("Sala %s fechada", r.Code)
+		return
+	}
+	if r.Host == c.ID {
+		r.Host = r.memberIDs()[0]
+	}
+	r.balanceBots()
+	r.announce()
+}
+
+// start begins the match with everyone in the room.
+func (r *Room) start() {
+	r.Started = true
+	r.state.Mode = r.Mode
+	r.state.Rules = sim.Rules{Rounds: r.Rounds, FriendlyFire: r.FriendlyFire, FreeForAll: r.FreeForAll}
+	for id, c := range r.members {
+		r.state.Players[id] = r.state.NewPlayer(id, c.Name)
+	}
+	r.balanceBots()
+	r.state.NewRun(rand.Uint64(), sim.Scripts(), sim.Projectiles())
+	if r.StartLevel > 1 {
+		r.state.StartLevel(r.StartLevel)
+	}
+	r.startRecording()
+	r.announce()
+	go r.run()
+}
+
+// run ticks the room's match until the room closes.
+func (r *Room) run() {
+	ticker := time.NewTicker(16 * time.Millisecond)
+	defer ticker.Stop()
+	for range ticker.C {
+		stateMutex.Lock()
+		if r.closed {
+			stateMutex.Unlock()
+			return
+		}
+		r.updateBots(sim.TickDT)
+		ended := r.state.Step(sim.TickDT)
+		snapshot := r.broadcastDelayed(r.state, time.Now())
+		r.recordTick(snapshot, ended)
+
+		var board sim.Scoreboard
+		var runs []RunRecord
+		if ended {
+			board = r.state.Scoreboard()
+			runs = finishedRuns(r.state)
+		}
+		stateMutex.Unlock()
+
+		if ended {
+			// Runs are stored before the scoreboard goes out so that clients
+			// fetching the leaderboard on game over already see them.
+			saveRuns(runs)
+			stateMutex.Lock()
+			if !r.closed {
+				r.record(replayEvent{Kind: "frame", Data: r.broadcastDelayed(board, time.Now())})
+				r.stopRecording()
+			}
+			stateMutex.Unlock()
+		}
+	}
+}
+
+func roomList() roomsMessage {
+	list := roomsMessage{Type: "rooms", Rooms: []RoomInfo{}}
+	for _, r := range rooms {
+		if r.Public {
+			list.Rooms = append(list.Rooms, r.info(false))
+		}
+	}
+	sort.Slice(list.Rooms, func(i, j int) bool { return list.Rooms[i].Code < list.Rooms[j].Code })
+	return list
+}
+
+// handleLobby runs a lobby command from c. Callers hold stateMutex.
+func handleLobby(c *Client, m Message) {
+	fail := func(msg string) {
+		c.sendJSON(errorMessage{Type: "error", Error: msg})
+	}
+	r := c.room
+	isHost := r != nil && r.Host == c.ID
+
+	switch m.Command {
+	case "list":
+		c.sendJSON(roomList())
+	case "create":
+		mode := m.Mode
+		if mode == "" {
+			mode = sim.ModeCoop
+		}
+		if !sim.ValidMode(mode) {
+			fail("modo de jogo desconhecido: " + mode)
+			return
+		}
+		maxPlayers := m.MaxPlayers
+		if maxPlayers == 0 {
+			maxPlayers = defaultMaxPlayers
+		}
+		if maxPlayers < 1 || maxPlayers > maxRoomPlayers {
+			fail("número de jogadores deve ser de 1 a 8")
+			return
+		}
+		name := strings.TrimSpace(m.Name)
+		if name == "" {
+			name = "Sala de " + c.Name
+		}
+		if len([]rune(name)) > maxRoomName {
+			name = string([]rune(name)[:maxRoomName])
+		}
+		if m.Difficulty != "" && sim.BotDifficulties[m.Difficulty].Name == "" {
+			fail("dificuldade desconhecida: " + m.Difficulty)
+			return
+		}
+		r := newRoom(name, m.Public, maxPlayers, mode)
+		r.Delay = max(0, min(m.Delay, maxSpectatorDelay))
+		r.Bots = max(0, min(m.Bots, maxPlayers-1))
+		if m.Difficulty != "" {
+			r.Difficulty = m.Difficulty
+		}
+		if m.Rounds != 0 {
+			r.Rounds = max(1, min(m.Rounds, sim.MaxRounds))
+		}
+		r.FriendlyFire, r.FreeForAll = m.FriendlyFire, m.FreeForAll
+		r.join(c)
+	case "join":
+		target, ok := rooms[strings.ToUpper(strings.TrimSpace(m.Room))]
+		switch {
+		case !ok:
+			fail("sala não encontrada")
+		case target == r:
+		case target.full():
+			fail("sala cheia")
+		default:
+			target.join(c)
+		}
+	case "spectate":
+		target, ok := rooms[strings.ToUpper(strings.TrimSpace(m.Room))]
+		switch {
+		case !ok:
+			fail("sala não encontrada")
+		case len(target.spectators) >= maxSpectators:
+			fail("sala sem vagas para espectadores")
+		default:
+			target.watch(c)
+		}
+	case "quick":
+		if r != nil {
+			r.leave(c, "")
+		}
+		enqueue(c)
+	case "cancel":
+		leaveQueue(c)
+	case "leave":
+		if r != nil {
+			r.leave(c, "")
+		}
+		c.sendJSON(roomList())
+	case "ready":
+		if r == nil || r.Started || c.spectator {
+			return
+		}
+		r.ready[c.ID] = m.Ready
+		r.announce()
+	case "start":
+		switch {
+		case !isHost:
+			fail("só o anfitrião pode iniciar")
+		case r.Started:
+		case !r.allReady():
+			fail("nem todos estão prontos")
+		default:
+			r.start()
+		}
+	case "kick":
+		target, ok := r.memberOrNil(m.Target)
+		switch {
+		case !isHost:
+			fail("só o anfitrião pode expulsar")
+		case !ok || target == c:
+			fail("jogador não está na sala")
+		default:
+			r.leave(target, "kicked")
+		}
+	case "bots":
+		switch {
+		case !isHost:
+			fail("só o anfitrião pode mudar os bots")
+		case m.Difficulty != "" && sim.BotDifficulties[m.Difficulty].Name == "":
+			fail("dificuldade desconhecida: " + m.Difficulty)
+		default:
+			r.Bots = max(0, min(m.Bots, r.MaxPlayers-1))
+			if m.Difficulty != "" {
+				r.Difficulty = m.Difficulty
+			}
+			r.balanceBots()
+			r.announce()
+		}
+	case "mode":
+		switch {
+		case !isHost:
+			fail("só o anfitrião pode mudar o modo")
+		case r.Started:
+			fail("a partida já começou")
+		case m.Mode != "" && !sim.ValidMode(m.Mode):
+			fail("modo de jogo desconhecido: " + m.Mode)
+		default:
+			if m.Mode != "" {
+				r.Mode = m.Mode
+			}
+			if m.Rounds != 0 {
+				r.Rounds = max(1, min(m.Rounds, sim.MaxRounds))
+			}
+			r.announce()
+		}
+	case "rules":
+		switch {
+		case !isHost:
+			fail("só o anfitrião pode mudar as regras")
+		case r.Started:
+			fail("a partida já começou")
+		default:
+			r.FriendlyFire, r.FreeForAll = m.FriendlyFire, m.FreeForAll
+			r.announce()
+		}
+	case "level":
+		levels := len(sim.Scripts())
+		switch {
+		case !isHost:
+			fail("só o anfitrião pode mudar o nível")
+		case m.Level < 1 || m.Level > levels:
+			fail("nível inválido")
+		case r.Started:
+			r.record(replayEvent{Kind: "level", Level: m.Level})
+			r.state.StartLevel(m.Level)
+			r.announce()
+		default:
+			r.StartLevel = m.Level
+			r.announce()
+		}
+	default:
+		fail("comando desconhecido: " + m.Command)
+	}
+}
+
+// memberOrNil finds a player or spectator of r by ID.
+func (r *Room) memberOrNil(id string) (*Client, bool) {
+	if r == nil {
+		return nil, false
+	}
+	if c, ok := r.members[id]; ok {
+		return c, true
+	}
+	c, ok := r.spectators[id]
+	return c, ok
+}
+
+// allReady reports whether everyone but the host is ready.
+func (r *Room) allReady() bool {
+	for id := range r.members {
+		if id != r.Host && !r.ready[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// roomsHandler serves GET /rooms: the public rooms and how full they are.
+func roomsHandler(c *fiber.Ctx) error {
+	stateMutex.Lock()
+	list := roomList()
+	stateMutex.Unlock()
+	return c.JSON(list.Rooms)
+}
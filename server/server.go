@@ -2,380 +2,234 @@ package main
 
 import (
 	"encoding/json"
-	"image/color"
+	"flag"
 	"log"
-	"math"
 	"math/rand/v2"
+	"os"
 	"sync"
-	"time"
+
+	"go-game/server/sim"
 
 	"github.com/gofiber/fiber/v2"
 	fws "github.com/gofiber/websocket/v2"
 )
 
-const (
-	screenWidth       = 800
-	screenHeight      = 600
-	groundY           = 500
-	playerX           = 100
-	playerWidth       = 20
-	playerHeight      = 40
-	gravity           = 800.0
-	jumpImpulse       = -350.0
-	playerBulletSpeed = 500.0
-	enemyBulletSpeed  = -300.0
-	periodSun         = 30.0
-)
-
-type Player struct {
-	ID string  `json:"id"`
-	Y  float64 `json:"y"`
-	Vy float64 `json:"vy"`
-}
-
-type Enemy struct {
-	X          float64 `json:"x"`
-	Y          float64 `json:"y"`
-	Vx         float64 `json:"vx"`
-	Vy         float64 `json:"vy"`
-	ShootTimer float64 `json:"shootTimer"`
-	Dead       bool    `json:"dead"`
-	DeathTimer float64 `json:"deathTimer"`
-	WalkPhase  float64 `json:"walkPhase"`
-}
-
-type Bullet struct {
-	X    float64 `json:"x"`
-	Y    float64 `json:"y"`
-	Vx   float64 `json:"vx"`
-	Vy   float64 `json:"vy"`
-	From string  `json:"from"`
-}
-
-type Sun struct {
-	X     float64     `json:"x"`
-	Y     float64     `json:"y"`
-	Color color.Color `json:"color"`
-}
-
-type GameState struct {
-	Sun      Sun                `json:"sun"`
-	Players  map[string]*Player `json:"players"`
-	Enemies  []*Enemy           `json:"enemies"`
-	Bullets  []*Bullet          `json:"bullets"`
-	Points   int                `json:"points"`
-	Level    int                `json:"level"`
-	time     float64
-	GameOver bool `json:"gameOver"`
-}
-
+// Message is what clients send. Gameplay commands have Type "command";
+// lobby commands have Type "lobby" and use the room fields.
 type Message struct {
-	Type     string `json:"type"`
-	PlayerID string `json:"playerId"`
-	Command  string `json:"command"`
-}
-
+	Type     string  `json:"type"`
+	PlayerID string  `json:"playerId"`
+	Command  string  `json:"command"`
+	AimX     float64 `json:"aimX,omitempty"`
+	AimY     float64 `json:"aimY,omitempty"`
+	Dir      int     `json:"dir,omitempty"`
+
+	Room       string  `json:"room,omitempty"`
+	Name       string  `json:"name,omitempty"`
+	Public     bool    `json:"public,omitempty"`
+	MaxPlayers int     `json:"maxPlayers,omitempty"`
+	Mode       string  `json:"mode,omitempty"`
+	Target     string  `json:"target,omitempty"`
+	Level      int     `json:"level,omitempty"`
+	Ready      bool    `json:"ready,omitempty"`
+	Delay      float64 `json:"delay,omitempty"`
+	Bots       int     `json:"bots,omitempty"`
+	Difficulty string  `json:"difficulty,omitempty"`
+	Rounds     int     `json:"rounds,omitempty"`
+
+	FriendlyFire bool `json:"friendlyFire,omitempty"`
+	FreeForAll   bool `json:"freeForAll,omitempty"`
+}
+
+// stateMutex guards the rooms, their matches and the clients' room
+// membership. Gym environments don't use it (see gymEnv).
 var (
-	gameState = GameState{
-		Players: make(map[string]*Player),
-		Enemies: []*Enemy{},
-		Bullets: []*Bullet{},
-		Points:  0,
-		Level:   1,
-	}
 	stateMutex sync.Mutex
 
 	clients      = make(map[string]*Client)
 	clientsMutex sync.Mutex
 )
 
+// clientQueueSize is how many outgoing messages may wait for a slow client
+// before new ones are dropped.
+const clientQueueSize = 64
+
+// Client is one websocket connection. Messages go out through a queue
+// drained by writeLoop, so rooms never block on a slow connection. room is
+// guarded by stateMutex.
 type Client struct {
 	ID   string
+	Name string
 	Conn *fws.Conn
-}
-
-func updateSun() (sunX, sunY float64, sunColor color.Color) {
-	centerX := float64(screenWidth) / 2
-	centerY := float64(screenHeight)
-	radius := float64(screenWidth) / 2
-	progress := math.Mod(gameState.time, periodSun) / periodSun
-	theta := math.Pi - progress*math.Pi
-	sunX = centerX + radius*math.Cos(theta)
-	sunY = centerY - radius*math.Sin(theta)
-	startG := 255.0
-	endG := 100.0
-	G := uint8(lerp(startG, endG, progress))
-	sunColor = color.RGBA{R: 255, G: G, B: 0, A: 255}
-	return
-}
-
-func updateEnemies(dt float64) {
-	if len(gameState.Enemies) == 0 {
-		enemy := Enemy{
-			X:          float64(screenWidth) + 50,
-			Y:          float64(groundY) - 10,
-			Vx:         -100 - float64(gameState.Level)*10,
-			Vy:         0,
-			ShootTimer: 2.0 + rand.Float64()*1.0,
-			Dead:       false,
-			DeathTimer: 0,
-			WalkPhase:  0,
-		}
-		gameState.Enemies = append(gameState.Enemies, &enemy)
-	}
-
-	for i := range gameState.Enemies {
-		if gameState.Enemies[i].Dead {
-			gameState.Enemies[i].DeathTimer += dt
-			gameState.Enemies[i].Vy += gravity * dt
-			gameState.Enemies[i].Y += gameState.Enemies[i].Vy * dt
-		} else {
-			gameState.Enemies[i].X += gameState.Enemies[i].Vx * dt
-			gameState.Enemies[i].WalkPhase += dt * 4
-			gameState.Enemies[i].ShootTimer -= dt
-			if gameState.Enemies[i].ShootTimer <= 0 {
-				bullet := Bullet{
-					X:    gameState.Enemies[i].X,
-					Y:    gameState.Enemies[i].Y - float64(playerHeight)/2,
-					Vx:   enemyBulletSpeed,
-					Vy:   0,
-					From: "enemy",
-				}
-				gameState.Bullets = append(gameState.Bullets, &bullet)
-				gameState.Enemies[i].ShootTimer = 1.5 + rand.Float64()*1.0
-			}
-		}
-	}
-
-	newEnemies := gameState.Enemies[:0]
-	for _, e := range gameState.Enemies {
-		if e.X > -50 && e.Y < float64(groundY)+100 {
-			newEnemies = append(newEnemies, e)
-		}
-	}
-	gameState.Enemies = newEnemies
-}
-
-func lerp(a, b, t float64) float64 {
-	return a + (b-a)*t
-}
 
-func rectsOverlap(a, b struct{ x, y, w, h float64 }) bool {
-	return a.x < b.x+b.w &&
-		a.x+a.w > b.x &&
-		a.y < b.y+b.h &&
-		a.y+a.h > b.y
+	out       chan []byte
+	room      *Room
+	spectator bool
 }
 
-func checkCollisions() {
-	for _, enemy := range gameState.Enemies {
-		if !enemy.Dead {
-			for _, bullet := range gameState.Bullets {
-				if bullet.From == "player" {
-					dx := enemy.X - bullet.X
-					dy := enemy.Y - bullet.Y
-					if math.Sqrt(dx*dx+dy*dy) < 15 {
-						enemy.Dead = true
-						enemy.DeathTimer = 0
-						enemy.Vy = 0
-						gameState.Points += 100
-						bullet.X = -1000
-						break
-					}
-				}
-			}
-		}
-	}
-
-	newBullets := gameState.Bullets[:0]
-	for _, bullet := range gameState.Bullets {
-		if bullet.X > 0 {
-			newBullets = append(newBullets, bullet)
-		}
-	}
-	gameState.Bullets = newBullets
-
-	for _, player := range gameState.Players {
-		playerRect := struct{ x, y, w, h float64 }{
-			x: float64(playerX) - float64(playerWidth)/2,
-			y: player.Y - float64(playerHeight),
-			w: float64(playerWidth),
-			h: float64(playerHeight),
-		}
-		for _, bullet := range gameState.Bullets {
-			if bullet.From == "enemy" {
-				bulletRect := struct{ x, y, w, h float64 }{
-					x: bullet.X - 2.5,
-					y: bullet.Y - 2.5,
-					w: 5,
-					h: 5,
-				}
-				if rectsOverlap(playerRect, bulletRect) {
-					// Game over logic here
-					log.Println("Game Over")
-				}
-			}
-		}
-	}
-
-	for _, enemy := range gameState.Enemies {
-		if !enemy.Dead {
-			for _, player := range gameState.Players {
-				dx := enemy.X - float64(playerX)
-				dy := enemy.Y - player.Y
-				if math.Sqrt(dx*dx+dy*dy) < 20 {
-					// Game over logic here
-					log.Println("Game Over")
-				}
-			}
+func (c *Client) writeLoop(done chan struct{}) {
+	defer close(done)
+	for data := range c.out {
+		if err := c.Conn.WriteMessage(1, data); err != nil {
+			log.Println("Erro ao enviar para", c.ID, err)
 		}
 	}
 }
 
-func gameLoop() {
-	ticker := time.NewTicker(16 * time.Millisecond)
-	dt := 1.0 / 60.0
-	for range ticker.C {
-		stateMutex.Lock()
-		gameState.time += dt
-		gameState.Sun.X, gameState.Sun.Y, gameState.Sun.Color = updateSun()
-
-		for _, p := range gameState.Players {
-			p.Vy += gravity * dt
-			p.Y += p.Vy * dt
-			if p.Y > float64(groundY) {
-				p.Y = float64(groundY)
-				p.Vy = 0
-			}
-		}
-
-		newPlayerBullets := gameState.Bullets[:0]
-		for _, b := range gameState.Bullets {
-			b.X += b.Vx * dt
-			b.Y += b.Vy * dt
-			if b.X > 0 && b.X < float64(screenWidth) && b.Y > 0 && b.Y < float64(screenHeight) {
-				newPlayerBullets = append(newPlayerBullets, b)
-			}
-		}
-		gameState.Bullets = newPlayerBullets
-
-		updateEnemies(dt)
-
-		checkCollisions()
-
-		stateMutex.Unlock()
-
-		broadcastGameState()
+// send queues data for c, dropping it if c is too far behind. Callers hold
+// stateMutex, which also keeps send from racing with the close in wsHandler.
+func (c *Client) send(data []byte) {
+	select {
+	case c.out <- data:
+	default:
+		log.Println("Fila cheia, mensagem descartada para", c.ID)
 	}
 }
 
-func broadcastGameState() {
-	stateMutex.Lock()
-	data, err := json.Marshal(gameState)
-	stateMutex.Unlock()
+func (c *Client) sendJSON(v any) {
+	data, err := json.Marshal(v)
 	if err != nil {
-		log.Println("Erro ao serializar o estado:", err)
+		log.Println("Erro ao serializar mensagem:", err)
 		return
 	}
-	clientsMutex.Lock()
-	defer clientsMutex.Unlock()
-	for _, c := range clients {
-		if err := c.Conn.WriteMessage(1, data); err != nil {
-			log.Println("Erro ao enviar para", c.ID, err)
-		}
-	}
+	c.send(data)
 }
 
 func wsHandler(c *fws.Conn) {
-	playerID := c.Query("id")
-	if playerID == "" {
-		playerID = c.RemoteAddr().String()
+	client := &Client{
+		ID:   c.Locals("playerID").(string),
+		Name: c.Locals("playerName").(string),
+		Conn: c,
+		out:  make(chan []byte, clientQueueSize),
 	}
-	client := &Client{ID: playerID, Conn: c}
 	clientsMutex.Lock()
-	clients[playerID] = client
+	clients[client.ID] = client // takes over requireSession's reservation
 	clientsMutex.Unlock()
-	log.Println("Cliente conectado:", playerID)
+	log.Println("Cliente conectado:", client.ID)
+
+	done := make(chan struct{})
+	go client.writeLoop(done)
 
 	stateMutex.Lock()
-	gameState.Players[playerID] = &Player{ID: playerID, Y: float64(groundY), Vy: 0}
+	client.sendJSON(roomList())
 	stateMutex.Unlock()
 
 	for {
 		_, msg, err := c.ReadMessage()
 		if err != nil {
-			log.Println("Erro ao ler mensagem de", playerID, ":", err)
+			log.Println("Erro ao ler mensagem de", client.ID, ":", err)
 			break
 		}
 		var m Message
 		if err := json.Unmarshal(msg, &m); err != nil {
-			log.Println("Erro ao decodificar mensagem de", playerID, ":", err)
+			log.Println("Erro ao decodificar mensagem de", client.ID, ":", err)
 			continue
 		}
-		handleMessage(m)
+		// Commands always act on the connection's own player, whatever ID
+		// the client put in the message.
+		m.PlayerID = client.ID
+		stateMutex.Lock()
+		switch {
+		case m.Type == "lobby":
+			handleLobby(client, m)
+		case client.room != nil && client.room.Started && !client.spectator:
+			client.room.handleCommand(m)
+		}
+		stateMutex.Unlock()
 	}
 
-	clientsMutex.Lock()
-	delete(clients, playerID)
-	clientsMutex.Unlock()
 	stateMutex.Lock()
-	delete(gameState.Players, playerID)
+	leaveQueue(client)
+	if client.room != nil {
+		client.room.leave(client, "")
+	}
+	close(client.out)
 	stateMutex.Unlock()
-	log.Println("Cliente desconectado:", playerID)
+	releaseClient(client.ID, client)
+	<-done
+	log.Println("Cliente desconectado:", client.ID)
 }
 
-func handleMessage(m Message) {
-	stateMutex.Lock()
-	defer stateMutex.Unlock()
-	p, ok := gameState.Players[m.PlayerID]
+// handleCommand applies a gameplay command to r. Callers hold stateMutex.
+func (r *Room) handleCommand(m Message) {
+	p, ok := r.state.Players[m.PlayerID]
 	if !ok {
 		return
 	}
-	switch m.Command {
-	case "reset":
-		p.Y = -float64(playerHeight)
-		p.Vy = 0
-		gameState.Points = 0
-		gameState.Level = 1
-		gameState.GameOver = false
-		gameState.Enemies = []*Enemy{}
-		gameState.Bullets = []*Bullet{}
-		gameState.time = 0
-		go func() {
-			for p.Y < float64(groundY) {
-				time.Sleep(16 * time.Millisecond)
-				stateMutex.Lock()
-				p.Vy += gravity * (1.0 / 60.0)
-				p.Y += p.Vy * (1.0 / 60.0)
-				if p.Y > float64(groundY) {
-					p.Y = float64(groundY)
-					p.Vy = 0
-				}
-				stateMutex.Unlock()
-			}
-		}()
-	case "jump":
-		if p.Y >= float64(groundY) {
-			p.Vy = jumpImpulse
+	if m.Command == "reset" {
+		// A running versus match can't be wiped by one side; it restarts
+		// once it has a winner.
+		if r.state.Versus != nil && !r.state.GameOver {
+			return
 		}
-	case "shoot":
-		bullet := &Bullet{
-			X:    float64(playerX),
-			Y:    p.Y - float64(playerHeight)/2,
-			Vx:   playerBulletSpeed,
-			Vy:   0,
-			From: "player",
+		if !r.state.GameOver {
+			go saveRuns(finishedRuns(r.state))
 		}
-		gameState.Bullets = append(gameState.Bullets, bullet)
+		r.state.NewRun(rand.Uint64(), sim.Scripts(), sim.Projectiles())
+		r.startRecording()
+		return
+	}
+	in := sim.Input{PlayerID: p.ID, Command: m.Command, Dir: m.Dir, AimX: m.AimX, AimY: m.AimY}
+	if r.state.ApplyInput(p, in) {
+		r.record(replayEvent{Kind: "input", Input: &in})
 	}
 }
 
 func main() {
-	go gameLoop()
+	wavesDir := flag.String("waves", "server/sim/waves", "diretório com os scripts de ondas (*.wave)")
+	check := flag.Bool("check", false, "valida os scripts de ondas e sai")
+	projectiles := flag.String("projectiles", "", "arquivo JSON com a tabela de projéteis (padrão: tabela embutida)")
+	verify := flag.Bool("verify", false, "re-simula os replays (arquivos ou diretórios) passados como argumentos, compara com a gravação e sai")
+	dbPath := flag.String("db", "scores.db", "arquivo com contas e placar persistente (vazio desativa ambos)")
+	gym := flag.Bool("gym", false, "habilita os ambientes de treino (/gym) para aprendizado por reforço")
+	flag.StringVar(&replaysDir, "replays", "replays", "diretório onde as partidas são gravadas (vazio desativa)")
+	flag.Parse()
+
+	if err := sim.LoadProjectiles(*projectiles); err != nil {
+		log.Fatal("Erro ao carregar projéteis: ", err)
+	}
+	if *verify {
+		os.Exit(verifyReplays(flag.Args()))
+	}
+
+	if *check {
+		os.Exit(sim.CheckScripts(*wavesDir))
+	}
+
+	sim.LoadScripts(*wavesDir)
+	go sim.WatchScripts(*wavesDir)
+
+	if *dbPath != "" {
+		db, err := openStore(*dbPath)
+		if err != nil {
+			log.Fatal("Erro ao abrir o banco de dados: ", err)
+		}
+		scores = &leaderboard{db: db}
+		if accounts, err = openAccounts(db); err != nil {
+			log.Fatal("Erro ao abrir as contas: ", err)
+		}
+	}
+
+	go matchmaker()
 
 	app := fiber.New()
 
-	app.Get("/ws", fws.New(wsHandler))
+	app.Post("/register", registerHandler)
+	app.Post("/login", loginHandler)
+	app.Get("/ws", requireSession, fws.New(wsHandler))
+	app.Get("/leaderboard", leaderboardHandler)
+	app.Get("/rooms", roomsHandler)
+	app.Get("/replays", replaysHandler)
+	app.Get("/replays/:name", replayFileHandler)
+	if *gym {
+		app.Post("/gym/envs", gymCreateHandler)
+		app.Post("/gym/envs/:id/reset", gymResetHandler)
+		app.Post("/gym/envs/:id/step", gymStepHandler)
+		app.Delete("/gym/envs/:id", gymDeleteHandler)
+		app.Get("/gym/ws", fws.New(gymWSHandler))
+		go reapGymEnvs()
+		log.Println("Ambientes de treino habilitados em /gym")
+	}
 
 	log.Println("Servidor iniciado na porta 3000")
 	log.Fatal(app.Listen(":3000"))
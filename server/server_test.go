@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"go-game/server/sim"
+)
+
+// TestVersusResetNeedsWinner checks that a player can't restart a versus
+// match that is still being played.
+func TestVersusResetNeedsWinner(t *testing.T) {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	r := newRoom("versus", false, 2, sim.ModeVersus)
+	defer delete(rooms, r.Code)
+	r.state.Mode = sim.ModeVersus
+	for _, id := range []string{"a", "b"} {
+		r.state.Players[id] = r.state.NewPlayer(id, id)
+	}
+	r.state.NewRun(1, sim.Scripts(), sim.Projectiles())
+	v := r.state.Versus
+	v.Wins[0] = 1
+
+	r.handleCommand(Message{PlayerID: "b", Command: "reset"})
+	if r.state.Versus != v || v.Wins[0] != 1 {
+		t.Fatal("reset wiped a running versus match")
+	}
+
+	r.state.GameOver = true
+	r.handleCommand(Message{PlayerID: "b", Command: "reset"})
+	if r.state.Versus == v || r.state.GameOver {
+		t.Error("reset ignored after the match ended")
+	}
+}
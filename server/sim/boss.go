@@ -0,0 +1,135 @@
+package sim
+
+import "math"
+
+const (
+	bossStopX     = screenWidth - 150
+	telegraphTime = 0.8
+)
+
+type bossPhase struct {
+	Above    float64
+	Cooldown float64
+	Attacks  []string
+}
+
+type bossDef struct {
+	Bonus  int
+	Phases []bossPhase
+}
+
+// bossDefs maps boss enemy kinds to their phases. A phase is active while the
+// boss' health fraction is above Above; phases are listed from first to last.
+var bossDefs = map[string]bossDef{
+	"boss": {
+		Bonus: 2000,
+		Phases: []bossPhase{
+			{Above: 0.66, Cooldown: 1.6, Attacks: []string{"aimed", "aimed", "spread"}},
+			{Above: 0.33, Cooldown: 1.2, Attacks: []string{"spread", "burst", "summon"}},
+			{Above: 0, Cooldown: 0.8, Attacks: []string{"burst", "homing", "spread", "aimed", "summon"}},
+		},
+	},
+}
+
+func bossPhaseFor(e *Enemy, def bossDef) int {
+	frac := float64(e.HP) / float64(max(e.MaxHP, 1))
+	for i, p := range def.Phases {
+		if frac > p.Above {
+			return i
+		}
+	}
+	return len(def.Phases) - 1
+}
+
+func (g *GameState) updateBoss(e *Enemy, dt float64) {
+	def := bossDefs[e.Kind]
+	if e.X <= bossStopX {
+		e.X = bossStopX
+		e.Vx = 0
+	}
+
+	phase := bossPhaseFor(e, def)
+	if phase != e.Phase {
+		e.Phase = phase
+		e.attackIndex = 0
+	}
+	p := def.Phases[phase]
+
+	if e.Attack != "" {
+		e.Telegraph -= dt
+		if e.Telegraph <= 0 {
+			g.bossAttack(e, e.Attack)
+			e.Attack = ""
+			e.ShootTimer = p.Cooldown / difficultyFor(g.Level).FireRate
+		}
+		return
+	}
+
+	e.ShootTimer -= dt
+	if e.ShootTimer <= 0 {
+		e.Attack = p.Attacks[e.attackIndex%len(p.Attacks)]
+		e.attackIndex++
+		e.Telegraph = telegraphTime
+	}
+}
+
+// bossProjectiles are the projectile types bossAttack fires; projectile
+// tables must define them.
+var bossProjectiles = []string{"bark", "enemy", "stinger"}
+
+func (g *GameState) bossAttack(e *Enemy, attack string) {
+	x, y := e.X, e.Y-float64(playerHeight)
+	aim := math.Pi
+	if p := g.targetPlayer(); p != nil {
+		aim = math.Atan2(p.Y-float64(playerHeight)/2-y, p.X-x)
+	}
+	switch attack {
+	case "aimed":
+		g.fireProjectile("bark", "enemy", x, y, aim)
+	case "burst":
+		g.fireSpread("bark", "enemy", x, y, aim, 3, 0.12)
+	case "spread":
+		g.fireSpread("enemy", "enemy", x, y, math.Pi, 5, 0.22)
+	case "homing":
+		g.fireProjectile("stinger", "enemy", x, y-30, math.Pi*0.75)
+		g.fireProjectile("stinger", "enemy", x, y+10, math.Pi*1.25)
+	case "summon":
+		for _, height := range []float64{0, 120} {
+			kind := "walker"
+			if height > 0 {
+				kind = "flyer"
+			}
+			add := g.spawnEnemy(kind, height)
+			add.X = e.X - 40
+		}
+	}
+}
+
+// targetPlayer picks the player with the lowest ID among those who can be
+// hit, so that aiming does not depend on map iteration order. It returns nil
+// when nobody is left standing.
+func (g *GameState) targetPlayer() *Player {
+	var target *Player
+	for id, p := range g.Players {
+		if !p.hittable() {
+			continue
+		}
+		if target == nil || id < target.ID {
+			target = p
+		}
+	}
+	return target
+}
+
+// defeatBoss awards the boss bonus to the player whose shot killed it.
+func (g *GameState) defeatBoss(e *Enemy, killer *Player) {
+	bonus := bossDefs[e.Kind].Bonus * g.Level
+	g.awardPoints(killer, bonus)
+	g.BossBonus = bonus
+}
+
+// hittable reports whether p is in play: not downed, out or waiting to
+// respawn.
+func (p *Player) hittable() bool {
+	return p.Lives > 0 && p.Downed == 0 && !p.Out
+}
@@ -0,0 +1,187 @@
+package sim
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+const (
+	botKeepAway      = 150.0
+	botApproach      = 350.0
+	botDodgeRange    = 140.0
+	botLineTolerance = 40.0
+)
+
+// BotDifficulty tunes how well a bot plays: Reaction is the time between
+// decisions, AimError the largest angle its shots miss by, Dodge the chance
+// it jumps over a threat it sees and Range how far away it shoots.
+type BotDifficulty struct {
+	Name     string
+	Reaction float64
+	AimError float64
+	Dodge    float64
+	Range    float64
+}
+
+var BotDifficulties = map[string]BotDifficulty{
+	"easy":   {Name: "fácil", Reaction: 0.5, AimError: 0.3, Dodge: 0.35, Range: 350},
+	"normal": {Name: "normal", Reaction: 0.25, AimError: 0.12, Dodge: 0.7, Range: 500},
+	"hard":   {Name: "difícil", Reaction: 0.1, AimError: 0.03, Dodge: 0.95, Range: 800},
+}
+
+// BotDecide picks a bot's commands: keep a safe distance from the nearest
+// enemy (or head for a downed teammate or an open end flag), jump over
+// bullets and enemies about to hit it, and shoot at the nearest enemy in
+// range.
+func (g *GameState) BotDecide(p *Player, diff BotDifficulty, rng *rand.Rand) []Input {
+	if g.versus() {
+		return g.botDuel(p, diff, rng)
+	}
+	var out []Input
+	chest := p.Y - float64(playerHeight)/2
+	target := g.nearestEnemy(p)
+
+	goal := p.X
+	switch f, down := g.endFlag(), g.nearestDowned(p); {
+	case down != nil:
+		goal = down.X
+	case f != nil && f.Open:
+		goal = f.X
+	case target != nil:
+		dx := target.X - p.X
+		switch {
+		case math.Abs(dx) < botKeepAway:
+			goal = p.X - math.Copysign(botKeepAway+50, dx)
+		case math.Abs(dx) > botApproach:
+			goal = target.X - math.Copysign(botApproach-50, dx)
+		}
+	}
+	goal = max(40, min(goal, screenWidth-40))
+	dir := 0
+	if math.Abs(goal-p.X) > 20 {
+		dir = int(math.Copysign(1, goal-p.X))
+	}
+	if dir != p.dir {
+		out = append(out, Input{Command: "move", Dir: dir})
+	}
+
+	if p.grounded && g.botThreatened(p, chest) && rng.Float64() < diff.Dodge {
+		out = append(out, Input{Command: "jump"})
+	}
+
+	if target != nil && p.shootCooldown <= 0 {
+		ty := target.Y - enemyKinds[target.Kind].Height/2
+		inLine := math.Abs(ty-chest) < botLineTolerance || enemyKinds[target.Kind].Flying || enemyKinds[target.Kind].Boss
+		if inLine && math.Hypot(target.X-p.X, ty-chest) <= diff.Range {
+			angle := math.Atan2(ty-chest, target.X-p.X) + (rng.Float64()*2-1)*diff.AimError
+			out = append(out, Input{Command: "shoot", AimX: p.X + 100*math.Cos(angle), AimY: chest + 100*math.Sin(angle)})
+		}
+	}
+	return out
+}
+
+// botThreatened reports whether an enemy bullet is flying at p's body or a
+// walking enemy is about to run into them.
+func (g *GameState) botThreatened(p *Player, chest float64) bool {
+	for _, b := range g.Bullets {
+		dx := b.X - p.X
+		if g.hostileBullet(b, p) && math.Abs(dx) < botDodgeRange && dx*b.Vx < 0 && math.Abs(b.Y-chest) < float64(playerHeight) {
+			return true
+		}
+	}
+	for _, e := range g.Enemies {
+		if !e.Dead && !enemyKinds[e.Kind].Flying && math.Abs(e.X-p.X) < botDodgeRange/2 {
+			return true
+		}
+	}
+	return false
+}
+
+// botDuel is BotDecide for versus matches: keep a shooting distance from
+// the nearest opponent, jump over their bullets and shoot at them.
+func (g *GameState) botDuel(p *Player, diff BotDifficulty, rng *rand.Rand) []Input {
+	var out []Input
+	chest := p.Y - float64(playerHeight)/2
+	foe := g.nearestOpponent(p)
+
+	dir := 0
+	if foe != nil {
+		dx := foe.X - p.X
+		switch {
+		case math.Abs(dx) < botKeepAway:
+			dir = -int(math.Copysign(1, dx))
+		case math.Abs(dx) > diff.Range*0.6:
+			dir = int(math.Copysign(1, dx))
+		}
+		if x := p.X + float64(dir)*40; x < 40 || x > screenWidth-40 {
+			dir = 0
+		}
+	}
+	if dir != p.dir {
+		out = append(out, Input{Command: "move", Dir: dir})
+	}
+
+	if p.grounded && g.botThreatened(p, chest) && rng.Float64() < diff.Dodge {
+		out = append(out, Input{Command: "jump"})
+	}
+
+	if foe != nil && p.shootCooldown <= 0 {
+		fy := foe.Y - float64(playerHeight)/2
+		if math.Hypot(foe.X-p.X, fy-chest) <= diff.Range {
+			angle := math.Atan2(fy-chest, foe.X-p.X) + (rng.Float64()*2-1)*diff.AimError
+			out = append(out, Input{Command: "shoot", AimX: p.X + 100*math.Cos(angle), AimY: chest + 100*math.Sin(angle)})
+		}
+	}
+	return out
+}
+
+// hostileBullet reports whether b can hurt p: enemy bullets always, and in
+// versus matches the other team's.
+func (g *GameState) hostileBullet(b *Bullet, p *Player) bool {
+	if b.From == "enemy" {
+		return true
+	}
+	if !g.versus() || b.Owner == p.ID {
+		return false
+	}
+	owner := g.bulletOwner(b)
+	return owner == nil || owner.Team != p.Team
+}
+
+func (g *GameState) nearestOpponent(p *Player) *Player {
+	var best *Player
+	bestDist := math.Inf(1)
+	for _, other := range g.SortedPlayers() {
+		if other.Lives <= 0 || other.Team == p.Team {
+			continue
+		}
+		if d := math.Hypot(other.X-p.X, other.Y-p.Y); d < bestDist {
+			best, bestDist = other, d
+		}
+	}
+	return best
+}
+
+func (g *GameState) nearestEnemy(p *Player) *Enemy {
+	var best *Enemy
+	bestDist := math.Inf(1)
+	for _, e := range g.Enemies {
+		if e.Dead {
+			continue
+		}
+		if d := math.Hypot(e.X-p.X, e.Y-p.Y); d < bestDist {
+			best, bestDist = e, d
+		}
+	}
+	return best
+}
+
+func (g *GameState) nearestDowned(p *Player) *Player {
+	var best *Player
+	for _, other := range g.SortedPlayers() {
+		if other.Downed > 0 && (best == nil || math.Abs(other.X-p.X) < math.Abs(best.X-p.X)) {
+			best = other
+		}
+	}
+	return best
+}
@@ -0,0 +1,107 @@
+package sim
+
+import "math"
+
+const (
+	gridCellSize = 64.0
+	gridMargin   = 128.0
+)
+
+// spatialGrid is a uniform-grid broadphase over the playfield, rebuilt every
+// tick. Boxes outside the field are clamped into the border cells. A zero
+// cellSize means gridCellSize.
+type spatialGrid[T comparable] struct {
+	cellSize   float64
+	cols, rows int
+	cells      [][]int
+	items      []gridItem[T]
+	marks      []int
+	stamp      int
+}
+
+type gridItem[T comparable] struct {
+	box aabb
+	v   T
+}
+
+func (g *spatialGrid[T]) reset() {
+	if g.cells == nil {
+		if g.cellSize == 0 {
+			g.cellSize = gridCellSize
+		}
+		g.cols = int(math.Ceil((screenWidth + 2*gridMargin) / g.cellSize))
+		g.rows = int(math.Ceil((2*screenHeight + gridMargin) / g.cellSize))
+		g.cells = make([][]int, g.cols*g.rows)
+	}
+	for i := range g.cells {
+		g.cells[i] = g.cells[i][:0]
+	}
+	g.items = g.items[:0]
+}
+
+func (g *spatialGrid[T]) cellRange(box aabb) (c0, r0, c1, r1 int) {
+	clamp := func(v, n int) int { return max(0, min(v, n-1)) }
+	c0 = clamp(int(math.Floor((box.X+gridMargin)/g.cellSize)), g.cols)
+	c1 = clamp(int(math.Floor((box.X+box.W+gridMargin)/g.cellSize)), g.cols)
+	r0 = clamp(int(math.Floor((box.Y+screenHeight)/g.cellSize)), g.rows)
+	r1 = clamp(int(math.Floor((box.Y+box.H+screenHeight)/g.cellSize)), g.rows)
+	return
+}
+
+func (g *spatialGrid[T]) insert(box aabb, v T) {
+	idx := len(g.items)
+	g.items = append(g.items, gridItem[T]{box, v})
+	c0, r0, c1, r1 := g.cellRange(box)
+	for r := r0; r <= r1; r++ {
+		for c := c0; c <= c1; c++ {
+			g.cells[r*g.cols+c] = append(g.cells[r*g.cols+c], idx)
+		}
+	}
+}
+
+// query calls fn once for every item whose cells intersect box, in insertion
+// order within each cell.
+func (g *spatialGrid[T]) query(box aabb, fn func(v T, box aabb)) {
+	if len(g.marks) < len(g.items) {
+		g.marks = make([]int, len(g.items))
+		g.stamp = 0
+	}
+	g.stamp++
+	c0, r0, c1, r1 := g.cellRange(box)
+	for r := r0; r <= r1; r++ {
+		for c := c0; c <= c1; c++ {
+			for _, idx := range g.cells[r*g.cols+c] {
+				if g.marks[idx] == g.stamp {
+					continue
+				}
+				g.marks[idx] = g.stamp
+				fn(g.items[idx].v, g.items[idx].box)
+			}
+		}
+	}
+}
+
+// rebuildBroadphase inserts every live enemy and every player into their
+// grids for this tick's pair queries.
+func (g *GameState) rebuildBroadphase() {
+	g.enemyGrid.reset()
+	for _, e := range g.Enemies {
+		if !e.Dead {
+			g.enemyGrid.insert(enemyBox(e), e)
+		}
+	}
+	g.playerGrid.reset()
+	for _, p := range g.SortedPlayers() {
+		g.playerGrid.insert(playerBox(p), p)
+	}
+}
+
+// sweptBounds is the box covering a swept box over its whole move.
+func sweptBounds(box aabb, dx, dy float64) aabb {
+	return aabb{
+		X: box.X + math.Min(dx, 0),
+		Y: box.Y + math.Min(dy, 0),
+		W: box.W + math.Abs(dx),
+		H: box.H + math.Abs(dy),
+	}
+}
@@ -0,0 +1,181 @@
+package sim
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"testing"
+)
+
+const (
+	benchBullets = 1000
+	benchEnemies = 200
+	benchPlayers = 4
+)
+
+// benchField is a crowded synthetic field: benchEnemies enemies and
+// benchBullets bullets spread over the screen, a fifth of them enemy fire.
+func benchField() ([]Enemy, []Bullet) {
+	rng := rand.New(rand.NewPCG(1, 1))
+	kinds := []string{"walker", "runner", "flyer"}
+	var enemies []Enemy
+	for range benchEnemies {
+		kind := kinds[rng.IntN(len(kinds))]
+		enemies = append(enemies, Enemy{
+			Kind: kind,
+			X:    rng.Float64() * screenWidth,
+			Y:    float64(groundY) - 10 - rng.Float64()*200,
+			Vx:   -enemyKinds[kind].Speed,
+			HP:   1 << 20,
+		})
+	}
+	var bullets []Bullet
+	for i := range benchBullets {
+		bl := Bullet{
+			X:    rng.Float64() * screenWidth,
+			Y:    rng.Float64() * groundY,
+			From: "player",
+			Type: "player",
+		}
+		if i%5 == 0 {
+			bl.From, bl.Type = "enemy", "enemy"
+		}
+		bl.Vx = Projectiles()[bl.Type].Speed
+		if bl.From == "enemy" {
+			bl.Vx = -bl.Vx
+		}
+		bl.prevX, bl.prevY = bl.X-bl.Vx/60, bl.Y
+		bullets = append(bullets, bl)
+	}
+	return enemies, bullets
+}
+
+// BenchmarkCheckCollisions times a whole collision pass on the bench field.
+func BenchmarkCheckCollisions(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	enemies, bullets := benchField()
+	g := NewGameState()
+	for i := range benchPlayers {
+		id := fmt.Sprintf("bench%d", i)
+		g.Players[id] = &Player{ID: id, Y: float64(groundY) - float64(i*20)}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		b.StopTimer()
+		g.Enemies = g.Enemies[:0]
+		for _, e := range enemies {
+			g.Enemies = append(g.Enemies, &e)
+		}
+		g.Bullets = g.Bullets[:0]
+		for _, bl := range bullets {
+			g.Bullets = append(g.Bullets, &bl)
+		}
+		b.StartTimer()
+		g.checkCollisions()
+	}
+}
+
+// benchPairs returns the boxes of the bench field's enemies and the swept
+// bounds of its bullets.
+func benchPairs() (boxes, queries []aabb) {
+	enemies, bullets := benchField()
+	g := NewGameState()
+	g.NewRun(1, nil, Projectiles())
+	for i := range enemies {
+		boxes = append(boxes, enemyBox(&enemies[i]))
+	}
+	for i := range bullets {
+		box, dx, dy := g.bulletSweep(&bullets[i])
+		queries = append(queries, sweptBounds(box, dx, dy))
+	}
+	return boxes, queries
+}
+
+// gridPairs finds the overlapping (query, box) index pairs through a grid
+// with the production cell size.
+func gridPairs(grid *spatialGrid[int], boxes, queries []aabb) [][2]int {
+	grid.reset()
+	for i, box := range boxes {
+		grid.insert(box, i)
+	}
+	var pairs [][2]int
+	for q, query := range queries {
+		grid.query(query, func(i int, box aabb) {
+			if box.overlaps(query) {
+				pairs = append(pairs, [2]int{q, i})
+			}
+		})
+	}
+	return pairs
+}
+
+// allPairs finds the same pairs by testing every query against every box.
+func allPairs(boxes, queries []aabb) [][2]int {
+	var pairs [][2]int
+	for q, query := range queries {
+		for i, box := range boxes {
+			if box.overlaps(query) {
+				pairs = append(pairs, [2]int{q, i})
+			}
+		}
+	}
+	return pairs
+}
+
+func TestGridMatchesAllPairs(t *testing.T) {
+	rng := rand.New(rand.NewPCG(2, 2))
+	randomBox := func() aabb {
+		// Some boxes stick out of the field, into the clamped border cells.
+		return aabb{
+			X: rng.Float64()*(screenWidth+400) - 200,
+			Y: rng.Float64()*(screenHeight+400) - 300,
+			W: 1 + rng.Float64()*150,
+			H: 1 + rng.Float64()*150,
+		}
+	}
+	var boxes, queries []aabb
+	for range 300 {
+		boxes = append(boxes, randomBox())
+		queries = append(queries, randomBox())
+	}
+	want := allPairs(boxes, queries)
+	got := gridPairs(&spatialGrid[int]{}, boxes, queries)
+	sortPairs := func(p [][2]int) {
+		slices.SortFunc(p, func(a, b [2]int) int { return cmp.Or(a[0]-b[0], a[1]-b[1]) })
+	}
+	sortPairs(want)
+	sortPairs(got)
+	if len(want) == 0 {
+		t.Fatal("random layout has no overlaps")
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("grid found %d pairs, all pairs %d", len(got), len(want))
+	}
+}
+
+func BenchmarkPairsGrid(b *testing.B) {
+	boxes, queries := benchPairs()
+	var grid spatialGrid[int]
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		gridPairs(&grid, boxes, queries)
+	}
+}
+
+func BenchmarkPairsAllPairs(b *testing.B) {
+	boxes, queries := benchPairs()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		allPairs(boxes, queries)
+	}
+}
@@ -0,0 +1,136 @@
+package sim
+
+import (
+	"math"
+	"slices"
+	"sort"
+)
+
+// aabb is an axis-aligned box given by its top-left corner and size. Every
+// entity uses one as its hitbox.
+type aabb struct {
+	X, Y, W, H float64
+}
+
+func (a aabb) overlaps(b aabb) bool {
+	return a.X < b.X+b.W &&
+		a.X+a.W > b.X &&
+		a.Y < b.Y+b.H &&
+		a.Y+a.H > b.Y
+}
+
+// sweep moves a by (dx, dy) and reports the fraction of the move, in [0, 1],
+// at which it first touches b. Boxes that already overlap hit at t = 0.
+func (a aabb) sweep(dx, dy float64, b aabb) (t float64, hit bool) {
+	if a.overlaps(b) {
+		return 0, true
+	}
+	// Grow b by a's size and cast a's top-left corner as a ray against it.
+	minX, maxX := b.X-a.W, b.X+b.W
+	minY, maxY := b.Y-a.H, b.Y+b.H
+	tMin, tMax := 0.0, 1.0
+	for _, axis := range [2]struct{ p, d, lo, hi float64 }{
+		{a.X, dx, minX, maxX},
+		{a.Y, dy, minY, maxY},
+	} {
+		if axis.d == 0 {
+			if axis.p <= axis.lo || axis.p >= axis.hi {
+				return 0, false
+			}
+			continue
+		}
+		t0 := (axis.lo - axis.p) / axis.d
+		t1 := (axis.hi - axis.p) / axis.d
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		tMin = math.Max(tMin, t0)
+		tMax = math.Min(tMax, t1)
+		if tMin >= tMax {
+			return 0, false
+		}
+	}
+	return tMin, true
+}
+
+func playerBox(p *Player) aabb {
+	return aabb{
+		X: p.X - float64(playerWidth)/2,
+		Y: p.Y - float64(playerHeight),
+		W: float64(playerWidth),
+		H: float64(playerHeight),
+	}
+}
+
+// enemyBox covers an enemy's body from the feet, drawn 20px below e.Y,
+// upwards.
+func enemyBox(e *Enemy) aabb {
+	kind := enemyKinds[e.Kind]
+	return aabb{
+		X: e.X - kind.Width/2,
+		Y: e.Y + 20 - kind.Height,
+		W: kind.Width,
+		H: kind.Height,
+	}
+}
+
+// bulletSweep returns a bullet's hitbox at the start of the tick and the
+// distance it travelled since, so that fast projectiles cannot skip over
+// thin targets.
+func (g *GameState) bulletSweep(b *Bullet) (box aabb, dx, dy float64) {
+	r := g.projectiles[b.Type].Radius
+	box = aabb{X: b.prevX - r, Y: b.prevY - r, W: 2 * r, H: 2 * r}
+	return box, b.X - b.prevX, b.Y - b.prevY
+}
+
+type bulletHit struct {
+	t     float64
+	enemy *Enemy
+}
+
+// enemiesHitBy lists the live enemies the bullet touched this tick, nearest
+// first, skipping the ones a piercing bullet has already gone through.
+func (g *GameState) enemiesHitBy(b *Bullet) []bulletHit {
+	box, dx, dy := g.bulletSweep(b)
+	var hits []bulletHit
+	g.enemyGrid.query(sweptBounds(box, dx, dy), func(e *Enemy, eBox aabb) {
+		if e.Dead || slices.Contains(b.hits, e) {
+			return
+		}
+		if t, ok := box.sweep(dx, dy, eBox); ok {
+			hits = append(hits, bulletHit{t, e})
+		}
+	})
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].t < hits[j].t })
+	return hits
+}
+
+// playersHitBy returns the players with lives left that enemy bullet b
+// crosses this tick, nearest first.
+func (g *GameState) playersHitBy(b *Bullet) []*Player {
+	box, dx, dy := g.bulletSweep(b)
+	type playerHit struct {
+		t float64
+		p *Player
+	}
+	var hits []playerHit
+	g.playerGrid.query(sweptBounds(box, dx, dy), func(p *Player, pBox aabb) {
+		if p.Lives <= 0 {
+			return
+		}
+		if t, ok := box.sweep(dx, dy, pBox); ok {
+			hits = append(hits, playerHit{t, p})
+		}
+	})
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].t != hits[j].t {
+			return hits[i].t < hits[j].t
+		}
+		return hits[i].p.ID < hits[j].p.ID
+	})
+	players := make([]*Player, len(hits))
+	for i, h := range hits {
+		players[i] = h.p
+	}
+	return players
+}
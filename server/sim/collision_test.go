@@ -0,0 +1,171 @@
+package sim
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSweep(t *testing.T) {
+	tests := []struct {
+		name   string
+		a      aabb
+		dx, dy float64
+		b      aabb
+		hit    bool
+		t      float64
+	}{
+		{
+			name: "fast bullet through thin wall",
+			a:    aabb{X: 0, Y: 0, W: 4, H: 4},
+			dx:   200,
+			b:    aabb{X: 100, Y: -10, W: 2, H: 30},
+			hit:  true,
+			t:    0.48,
+		},
+		{
+			name: "fast bullet stopping short",
+			a:    aabb{X: 0, Y: 0, W: 4, H: 4},
+			dx:   90,
+			b:    aabb{X: 100, Y: -10, W: 2, H: 30},
+		},
+		{
+			name: "resting against an edge",
+			a:    aabb{X: 0, Y: 0, W: 4, H: 4},
+			b:    aabb{X: 4, Y: 0, W: 4, H: 4},
+		},
+		{
+			name: "sliding along an edge",
+			a:    aabb{X: 0, Y: 0, W: 4, H: 4},
+			dx:   100,
+			b:    aabb{X: 50, Y: 4, W: 10, H: 10},
+		},
+		{
+			name: "overlap without moving",
+			a:    aabb{X: 0, Y: 0, W: 4, H: 4},
+			b:    aabb{X: 2, Y: 2, W: 4, H: 4},
+			hit:  true,
+			t:    0,
+		},
+		{
+			name: "diagonal hit",
+			a:    aabb{X: 0, Y: 0, W: 2, H: 2},
+			dx:   100,
+			dy:   100,
+			b:    aabb{X: 50, Y: 50, W: 10, H: 10},
+			hit:  true,
+			t:    0.48,
+		},
+		{
+			name: "diagonal miss",
+			a:    aabb{X: 0, Y: 0, W: 2, H: 2},
+			dx:   100,
+			dy:   100,
+			b:    aabb{X: 80, Y: 10, W: 10, H: 10},
+		},
+		{
+			name: "moving away",
+			a:    aabb{X: 0, Y: 0, W: 4, H: 4},
+			dx:   -100,
+			b:    aabb{X: 10, Y: 0, W: 4, H: 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, hit := tt.a.sweep(tt.dx, tt.dy, tt.b)
+			if hit != tt.hit {
+				t.Fatalf("hit = %v, want %v", hit, tt.hit)
+			}
+			if hit && math.Abs(got-tt.t) > 1e-9 {
+				t.Errorf("t = %v, want %v", got, tt.t)
+			}
+		})
+	}
+}
+
+func TestEnemiesHitByOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		hits []int
+		want []float64
+	}{
+		{name: "nearest first", want: []float64{200, 300, 400}},
+		{name: "skips enemies already pierced", hits: []int{1}, want: []float64{300, 400}},
+		{name: "all pierced", hits: []int{0, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t, ModeCoop, Rules{})
+			for _, x := range []float64{400, 200, 300} {
+				g.Enemies = append(g.Enemies, &Enemy{Kind: "walker", X: x, Y: 400, HP: 1})
+			}
+			b := &Bullet{From: "player", Type: "player", X: 500, Y: 400, prevX: 100, prevY: 400, pierce: 3}
+			for _, i := range tt.hits {
+				b.hits = append(b.hits, g.Enemies[i])
+			}
+			g.rebuildBroadphase()
+			var got []float64
+			for _, h := range g.enemiesHitBy(b) {
+				got = append(got, h.enemy.X)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("hit %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("hit %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBulletLeavingScreenStillHits(t *testing.T) {
+	g := newTestGame(t, ModeCoop, Rules{})
+	e := &Enemy{Kind: "walker", X: 790, Y: 400, HP: 1, MaxHP: 1}
+	g.Enemies = []*Enemy{e}
+	g.Bullets = []*Bullet{{From: "player", Type: "player", X: 770, Y: 400, Vx: 3000}}
+	g.updateBullets(TickDT)
+	g.checkCollisions()
+	if !e.Dead {
+		t.Error("bullet that crossed the enemy on its way off screen missed it")
+	}
+	if len(g.Bullets) != 0 {
+		t.Errorf("%d bullets left after leaving the screen", len(g.Bullets))
+	}
+}
+
+// TestEnemyBulletStackedPlayers fires an enemy bullet through two players
+// standing one behind the other.
+func TestEnemyBulletStackedPlayers(t *testing.T) {
+	tests := []struct {
+		name   string
+		pierce int
+		hit    map[string]bool
+		left   int
+	}{
+		{name: "stops", hit: map[string]bool{"a": true, "b": false}},
+		{name: "pierces one", pierce: 1, hit: map[string]bool{"a": true, "b": true}},
+		{name: "pierces both", pierce: 2, hit: map[string]bool{"a": true, "b": true}, left: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t, ModeCoop, Rules{})
+			for id, x := range map[string]float64{"a": 300, "b": 250} {
+				p := g.NewPlayer(id, id)
+				p.X, p.Y, p.Invulnerable = x, groundY, 0
+				g.Players[id] = p
+			}
+			y := float64(groundY) - playerHeight/2
+			g.Bullets = []*Bullet{{From: "enemy", Type: "enemy", X: 200, Y: y, prevX: 400, prevY: y, pierce: tt.pierce}}
+			g.checkCollisions()
+			for id, want := range tt.hit {
+				if hit := g.Players[id].Stats.DamageTaken > 0; hit != want {
+					t.Errorf("player %s hit = %v, want %v", id, hit, want)
+				}
+			}
+			if len(g.Bullets) != tt.left {
+				t.Errorf("%d bullets left, want %d", len(g.Bullets), tt.left)
+			}
+		})
+	}
+}
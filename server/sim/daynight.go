@@ -0,0 +1,76 @@
+package sim
+
+import (
+	"image/color"
+	"math"
+)
+
+// dayLength is how long a full day takes in seconds of game time. The first
+// half is day, with the sun crossing the sky; the second half is night, with
+// the moon.
+const dayLength = 120.0
+
+// swoopSpeed is how fast swooping enemies close in vertically, in px/s.
+const swoopSpeed = 60.0
+
+// nightKinds are the enemies that replace the daytime ones after dusk.
+var nightKinds = map[string]string{
+	"walker": "ghost",
+	"runner": "ghost",
+	"flyer":  "bat",
+}
+
+// Moon is the moon's position on screen. It is only up at night.
+type Moon struct {
+	X  float64 `json:"x"`
+	Y  float64 `json:"y"`
+	Up bool    `json:"up"`
+}
+
+// dayTime is how far into the current day the match is, from 0 at sunrise
+// through 0.5 at sunset back to 1 at the next sunrise.
+func (g *GameState) dayTime() float64 {
+	return math.Mod(g.time, dayLength) / dayLength
+}
+
+func (g *GameState) isNight() bool {
+	return g.dayTime() >= 0.5
+}
+
+// skyArc places a body on the arc across the sky, progress 0 rising on the
+// left and 1 setting on the right.
+func skyArc(progress float64) (x, y float64) {
+	centerX := float64(screenWidth) / 2
+	centerY := float64(screenHeight)
+	radius := float64(screenWidth) / 2
+	theta := math.Pi - progress*math.Pi
+	return centerX + radius*math.Cos(theta), centerY - radius*math.Sin(theta)
+}
+
+// updateSky moves the sun and moon along with the clock. The sun turns
+// orange as it sets.
+func (g *GameState) updateSky() {
+	t := g.dayTime()
+	g.DayTime = t
+	g.Night = t >= 0.5
+	if !g.Night {
+		progress := t * 2
+		g.Sun.X, g.Sun.Y = skyArc(progress)
+		G := uint8(lerp(255, 100, progress))
+		g.Sun.Color = color.RGBA{R: 255, G: G, B: 0, A: 255}
+		g.Moon = Moon{}
+		return
+	}
+	g.Sun.X, g.Sun.Y = skyArc(1)
+	g.Moon.X, g.Moon.Y = skyArc(t*2 - 1)
+	g.Moon.Up = true
+}
+
+// nightKind returns the kind to spawn in place of kindName, which differs
+// only at night.
+func (g *GameState) nightKind(kindName string) string {
+	if k, ok := nightKinds[kindName]; ok && g.isNight() {
+		return k
+	}
+	return kindName
+}
@@ -0,0 +1,368 @@
+// Package sim is the game simulation. A GameState holds one match and
+// advances it with Step; it shares nothing with other GameStates, so
+// matches can run side by side, each from its own goroutine. A GameState
+// itself is not safe for concurrent use.
+package sim
+
+import (
+	"image/color"
+	"math"
+	"math/rand/v2"
+)
+
+const (
+	screenWidth  = 800
+	screenHeight = 600
+	groundY      = 500
+	playerX      = 100
+	playerWidth  = 20
+	playerHeight = 40
+	gravity      = 800.0
+	jumpImpulse  = -350.0
+	TickDT       = 1.0 / 60.0
+)
+
+type Player struct {
+	ID           string             `json:"id"`
+	Name         string             `json:"name"`
+	X            float64            `json:"x"`
+	Y            float64            `json:"y"`
+	Vx           float64            `json:"vx"`
+	Vy           float64            `json:"vy"`
+	Facing       int                `json:"facing"`
+	Lives        int                `json:"lives"`
+	Buffs        map[string]float64 `json:"buffs"`
+	Invulnerable float64            `json:"invulnerable"`
+
+	RespawnX float64     `json:"respawnX"`
+	State    string      `json:"state"`
+	Stats    PlayerStats `json:"stats"`
+	Bot      bool        `json:"bot,omitempty"`
+	Team     int         `json:"team,omitempty"`
+
+	// Downed is the bleed-out time left while the player waits for a
+	// revive, Revive how far a teammate has got reviving them, and Out is
+	// set while they sit out until the next level (or versus round).
+	Downed float64 `json:"downed,omitempty"`
+	Revive float64 `json:"revive,omitempty"`
+	Out    bool    `json:"out,omitempty"`
+
+	// Respawn is the time left before a player who lost their last life
+	// comes back, in modes without revives.
+	Respawn float64 `json:"respawn,omitempty"`
+
+	shootCooldown float64
+	airJumps      int
+	dir           int
+	grounded      bool
+	standing      *Trap
+	coyote        float64
+	jumpBuffer    float64
+	jumping       bool
+	wallDir       int
+	wallLock      float64
+}
+
+type Enemy struct {
+	Kind       string  `json:"kind"`
+	X          float64 `json:"x"`
+	Y          float64 `json:"y"`
+	Vx         float64 `json:"vx"`
+	Vy         float64 `json:"vy"`
+	ShootTimer float64 `json:"shootTimer"`
+	Dead       bool    `json:"dead"`
+	DeathTimer float64 `json:"deathTimer"`
+	WalkPhase  float64 `json:"walkPhase"`
+	HP         int     `json:"hp"`
+	MaxHP      int     `json:"maxHp"`
+	Phase      int     `json:"phase"`
+	Attack     string  `json:"attack,omitempty"`
+	Telegraph  float64 `json:"telegraph,omitempty"`
+
+	attackIndex int
+}
+
+type Bullet struct {
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	Vx    float64 `json:"vx"`
+	Vy    float64 `json:"vy"`
+	From  string  `json:"from"`
+	Type  string  `json:"type"`
+	Owner string  `json:"owner,omitempty"`
+
+	age          float64
+	prevX, prevY float64
+	pierce       int
+	hits         []*Enemy
+	// spent is set once the bullet hits something it stops on; it is
+	// removed at the end of the tick.
+	spent bool
+}
+
+type Sun struct {
+	X     float64     `json:"x"`
+	Y     float64     `json:"y"`
+	Color color.Color `json:"color"`
+}
+
+// GameState is one match: what clients see, plus the run's private state.
+type GameState struct {
+	Sun      Sun                `json:"sun"`
+	Moon     Moon               `json:"moon"`
+	Players  map[string]*Player `json:"players"`
+	Enemies  []*Enemy           `json:"enemies"`
+	Bullets  []*Bullet          `json:"bullets"`
+	Items    []*Item            `json:"items"`
+	Boxes    []*Box             `json:"boxes"`
+	Flags    []*Flag            `json:"flags"`
+	Traps    []*Trap            `json:"traps"`
+	Surfaces []Surface          `json:"surfaces"`
+	Points   int                `json:"points"`
+	Level    int                `json:"level"`
+	time     float64
+	GameOver bool `json:"gameOver"`
+
+	Wave         int     `json:"wave"`
+	Intermission float64 `json:"intermission"`
+	Seed         uint64  `json:"seed"`
+	BossBonus    int     `json:"bossBonus"`
+
+	// DayTime runs from 0 at sunrise to 1 at the next one (see dayLength);
+	// Night is set for its second half.
+	DayTime float64 `json:"dayTime"`
+	Night   bool    `json:"night"`
+	Weather Weather `json:"weather"`
+
+	// Mode is the room's game mode (see GameMode). Clock is the time left
+	// in time attack matches and Versus the round state of versus ones.
+	Mode   string  `json:"mode,omitempty"`
+	Clock  float64 `json:"clock,omitempty"`
+	Versus *Versus `json:"versus,omitempty"`
+	Rules  Rules   `json:"-"`
+
+	waves       waveState
+	levels      []LevelDef
+	projectiles map[string]ProjectileDef
+	rng         *rand.Rand
+
+	enemyGrid  spatialGrid[*Enemy]
+	playerGrid spatialGrid[*Player]
+}
+
+func NewGameState() *GameState {
+	return &GameState{
+		Players:  make(map[string]*Player),
+		Enemies:  []*Enemy{},
+		Bullets:  []*Bullet{},
+		Items:    []*Item{},
+		Boxes:    []*Box{},
+		Flags:    []*Flag{},
+		Traps:    []*Trap{},
+		Surfaces: []Surface{},
+		Points:   0,
+		Level:    1,
+		Mode:     ModeCoop,
+	}
+}
+
+func (g *GameState) updateEnemies(dt float64) {
+	for i := range g.Enemies {
+		if g.Enemies[i].Dead {
+			g.Enemies[i].DeathTimer += dt
+			g.Enemies[i].Vy += gravity * dt
+			g.Enemies[i].Y += g.Enemies[i].Vy * dt
+		} else {
+			kind := enemyKinds[g.Enemies[i].Kind]
+			g.Enemies[i].X += g.Enemies[i].Vx * dt
+			g.Enemies[i].WalkPhase += dt * 4
+			if kind.Flying {
+				g.Enemies[i].Y += math.Cos(g.Enemies[i].WalkPhase) * 20 * dt
+			}
+			if kind.Boss {
+				g.updateBoss(g.Enemies[i], dt)
+				continue
+			}
+			if kind.Swoop {
+				if p := g.targetPlayer(); p != nil {
+					dy := p.Y - g.Enemies[i].Y
+					g.Enemies[i].Y += math.Copysign(math.Min(math.Abs(dy), swoopSpeed*dt), dy)
+				}
+			}
+			if kind.Projectile == "" {
+				continue
+			}
+			g.Enemies[i].ShootTimer -= dt
+			if g.Enemies[i].ShootTimer <= 0 {
+				x, y := g.Enemies[i].X, g.Enemies[i].Y-float64(playerHeight)/2
+				if p := g.targetPlayer(); kind.Aimed && p != nil {
+					g.fireAimed(kind.Projectile, "enemy", x, y, p.X, p.Y-float64(playerHeight)/2)
+				} else {
+					g.fireProjectile(kind.Projectile, "enemy", x, y, math.Pi)
+				}
+				g.Enemies[i].ShootTimer = (kind.ShootMin + g.rng.Float64()*kind.ShootRand) / difficultyFor(g.Level).FireRate
+			}
+		}
+	}
+
+	newEnemies := g.Enemies[:0]
+	for _, e := range g.Enemies {
+		if e.X > -50 && e.Y < float64(groundY)+100 {
+			newEnemies = append(newEnemies, e)
+		}
+	}
+	g.Enemies = newEnemies
+}
+
+func lerp(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
+
+func (g *GameState) checkCollisions() {
+	g.rebuildBroadphase()
+
+	for _, bullet := range g.Bullets {
+		if bullet.From != "player" {
+			continue
+		}
+		def := g.projectiles[bullet.Type]
+		owner := g.bulletOwner(bullet)
+		for _, hit := range g.enemiesHitBy(bullet) {
+			enemy := hit.enemy
+			kind := enemyKinds[enemy.Kind]
+			if len(bullet.hits) == 0 && owner != nil {
+				owner.Stats.Hits++
+			}
+			bullet.hits = append(bullet.hits, enemy)
+			enemy.HP -= def.Damage
+			if enemy.HP <= 0 {
+				enemy.Dead = true
+				enemy.DeathTimer = 0
+				enemy.Vy = 0
+				g.currentMode().OnKill(g, owner, enemy)
+				g.dropLoot(enemy)
+				if kind.Boss {
+					g.defeatBoss(enemy, owner)
+				}
+			}
+			if bullet.pierce == 0 {
+				bullet.spent = true
+				break
+			}
+			bullet.pierce--
+		}
+	}
+
+	g.hitBoxes()
+
+	if g.versus() {
+		g.hitPlayers()
+	}
+
+	for _, bullet := range g.Bullets {
+		if bullet.From != "enemy" || bullet.spent {
+			continue
+		}
+		// Invulnerable players let bullets through; the others stop them
+		// unless the bullet pierces.
+		for _, player := range g.playersHitBy(bullet) {
+			if player.Invulnerable > 0 {
+				continue
+			}
+			g.damagePlayer(player)
+			if bullet.pierce == 0 {
+				bullet.spent = true
+				break
+			}
+			bullet.pierce--
+		}
+	}
+
+	for _, player := range g.SortedPlayers() {
+		box := playerBox(player)
+		g.enemyGrid.query(box, func(enemy *Enemy, eBox aabb) {
+			if !enemy.Dead && eBox.overlaps(box) {
+				g.damagePlayer(player)
+			}
+		})
+	}
+
+	g.collectItems()
+	g.touchFlags()
+	g.trapHazards()
+	g.cullBullets()
+}
+
+// Step advances the match by dt and reports whether it ended during this
+// tick.
+func (g *GameState) Step(dt float64) bool {
+	if g.GameOver {
+		return false
+	}
+	g.time += dt
+	g.updateSky()
+
+	g.updateTraps(dt)
+
+	g.updatePlayers(dt)
+
+	g.currentMode().OnTick(g, dt)
+
+	g.updateBullets(dt)
+
+	g.updateEnemies(dt)
+
+	g.updateItems(dt)
+
+	g.updateLevelObjects(dt)
+
+	g.checkCollisions()
+
+	if g.currentMode().CheckWinCondition(g) {
+		g.GameOver = true
+	}
+	return g.GameOver
+}
+
+// Elapsed is the simulated time since the run started, in seconds.
+func (g *GameState) Elapsed() float64 {
+	return g.time
+}
+
+// Levels returns the level scripts the run was started with.
+func (g *GameState) Levels() []LevelDef {
+	return g.levels
+}
+
+// Projectiles returns the projectile table the run was started with.
+func (g *GameState) Projectiles() map[string]ProjectileDef {
+	return g.projectiles
+}
+
+// Input is a player's command: move (with Dir), jump, jumpRelease or shoot
+// (at AimX, AimY). Replays store them as they are.
+type Input struct {
+	PlayerID string  `json:"playerId"`
+	Command  string  `json:"command"`
+	AimX     float64 `json:"aimX,omitempty"`
+	AimY     float64 `json:"aimY,omitempty"`
+	Dir      int     `json:"dir,omitempty"`
+}
+
+// ApplyInput applies a movement or shooting command to p and reports
+// whether m was one.
+func (g *GameState) ApplyInput(p *Player, m Input) bool {
+	switch m.Command {
+	case "move":
+		p.dir = max(-1, min(m.Dir, 1))
+	case "jump":
+		playerJump(p)
+	case "jumpRelease":
+		playerJumpRelease(p)
+	case "shoot":
+		g.playerShoot(p, m)
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,213 @@
+package sim
+
+import (
+	"math"
+	"sort"
+)
+
+// The gym exposes the simulation as a reinforcement learning environment:
+// Reset starts a run from a seed, Step applies one action and returns what
+// the agent observes, its reward and whether the episode is over. Nothing is
+// rendered and nothing waits for the clock, so episodes run as fast as the
+// simulation does. Every Env owns its GameState, so environments can step in
+// parallel; a single Env must not be used from two goroutines at once.
+const (
+	gymAgentID         = "agent"
+	gymEnemySlots      = 5
+	gymBulletSlots     = 8
+	gymDefaultRepeat   = 4
+	gymDefaultMaxSteps = 5000
+
+	// ObsSize is the length of an observation: 10 values about the agent
+	// and the run, then 5 per enemy slot and 5 per bullet slot.
+	ObsSize = 10 + 5*gymEnemySlots + 5*gymBulletSlots
+)
+
+// Action is what the agent does during one step. Jump is the button state:
+// pressing it jumps (or double jumps) and letting go early cuts the jump
+// short, as with a human player. Shots aim at (AimX, AimY), or straight ahead
+// when both are zero.
+type Action struct {
+	Move  int     `json:"move"`
+	Jump  bool    `json:"jump"`
+	Shoot bool    `json:"shoot"`
+	AimX  float64 `json:"aimX"`
+	AimY  float64 `json:"aimY"`
+}
+
+// StepResult is what Reset and Step return. Observation always has
+// ObsSize values, roughly scaled to [-1, 1]:
+//
+//	0-9    agent x, y, vx, vy, on ground, lives, invulnerable, can shoot,
+//	       level, between levels
+//	10-34  the 5 nearest live enemies: present, dx, dy, vx, flying
+//	35-74  the 8 nearest enemy bullets: present, dx, dy, vx, vy
+//
+// Slots without an enemy or bullet are all zeros. Reward is the points the
+// agent scored during the step divided by 100, minus 1 for every hit taken.
+// Done is set when the agent is out of lives; Truncated when the episode hit
+// its step limit instead.
+type StepResult struct {
+	Observation []float64 `json:"observation"`
+	Reward      float64   `json:"reward"`
+	Done        bool      `json:"done"`
+	Truncated   bool      `json:"truncated"`
+	Info        GymInfo   `json:"info"`
+}
+
+type GymInfo struct {
+	Step   int `json:"step"`
+	Score  int `json:"score"`
+	Level  int `json:"level"`
+	Lives  int `json:"lives"`
+	Deaths int `json:"deaths"`
+}
+
+// Env is one environment. Repeat is how many simulation ticks one Step
+// covers and MaxSteps how many steps an episode may last.
+type Env struct {
+	Repeat   int
+	MaxSteps int
+
+	state   *GameState
+	steps   int
+	jumping bool
+	score   int
+	hits    int
+}
+
+func NewEnv(repeat, maxSteps int) *Env {
+	if repeat <= 0 {
+		repeat = gymDefaultRepeat
+	}
+	if maxSteps <= 0 {
+		maxSteps = gymDefaultMaxSteps
+	}
+	return &Env{Repeat: repeat, MaxSteps: maxSteps, state: NewGameState()}
+}
+
+// Reset starts a new episode from level 1 with the given seed.
+func (e *Env) Reset(seed uint64) StepResult {
+	g := e.state
+	g.Players = map[string]*Player{gymAgentID: g.NewPlayer(gymAgentID, "Agente")}
+	g.NewRun(seed, Scripts(), Projectiles())
+	p := g.Players[gymAgentID]
+	p.Y = float64(groundY)
+	e.steps, e.jumping, e.score, e.hits = 0, false, 0, 0
+	return e.result(p, 0)
+}
+
+// Step applies a for Repeat ticks.
+func (e *Env) Step(a Action) StepResult {
+	g := e.state
+	p := g.Players[gymAgentID]
+	if p == nil {
+		// Step before the first Reset.
+		g.Players = map[string]*Player{gymAgentID: g.NewPlayer(gymAgentID, "Agente")}
+		g.NewRun(0, Scripts(), Projectiles())
+		p = g.Players[gymAgentID]
+	}
+
+	g.ApplyInput(p, Input{Command: "move", Dir: a.Move})
+	switch {
+	case a.Jump && !e.jumping:
+		g.ApplyInput(p, Input{Command: "jump"})
+	case !a.Jump && e.jumping:
+		g.ApplyInput(p, Input{Command: "jumpRelease"})
+	}
+	e.jumping = a.Jump
+	if a.Shoot {
+		g.ApplyInput(p, Input{Command: "shoot", AimX: a.AimX, AimY: a.AimY})
+	}
+	for range e.Repeat {
+		if g.Step(TickDT) || p.Lives <= 0 {
+			break
+		}
+	}
+	e.steps++
+
+	reward := float64(p.Stats.Score-e.score)/100 - float64(p.Stats.DamageTaken-e.hits)
+	e.score, e.hits = p.Stats.Score, p.Stats.DamageTaken
+	return e.result(p, reward)
+}
+
+func (e *Env) result(p *Player, reward float64) StepResult {
+	g := e.state
+	done := p.Lives <= 0 || g.GameOver
+	return StepResult{
+		Observation: g.observe(p),
+		Reward:      reward,
+		Done:        done,
+		Truncated:   !done && e.steps >= e.MaxSteps,
+		Info: GymInfo{
+			Step:   e.steps,
+			Score:  p.Stats.Score,
+			Level:  g.Level,
+			Lives:  p.Lives,
+			Deaths: p.Stats.Deaths,
+		},
+	}
+}
+
+func boolObs(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// observe builds the observation vector described on StepResult.
+func (g *GameState) observe(p *Player) []float64 {
+	obs := make([]float64, 0, ObsSize)
+	chest := p.Y - float64(playerHeight)/2
+	obs = append(obs,
+		p.X/screenWidth,
+		p.Y/screenHeight,
+		p.Vx/playerSpeed,
+		p.Vy/-jumpImpulse,
+		boolObs(p.grounded),
+		float64(p.Lives)/maxLives,
+		boolObs(p.Invulnerable > 0),
+		boolObs(p.shootCooldown <= 0),
+		float64(g.Level)/10,
+		boolObs(g.Intermission > 0),
+	)
+
+	var enemies []*Enemy
+	for _, en := range g.Enemies {
+		if !en.Dead {
+			enemies = append(enemies, en)
+		}
+	}
+	sort.SliceStable(enemies, func(i, j int) bool {
+		return math.Abs(enemies[i].X-p.X) < math.Abs(enemies[j].X-p.X)
+	})
+	for i := range gymEnemySlots {
+		if i >= len(enemies) {
+			obs = append(obs, 0, 0, 0, 0, 0)
+			continue
+		}
+		en := enemies[i]
+		kind := enemyKinds[en.Kind]
+		obs = append(obs, 1, (en.X-p.X)/screenWidth, (en.Y-kind.Height/2-chest)/screenHeight, en.Vx/playerSpeed, boolObs(kind.Flying))
+	}
+
+	var bullets []*Bullet
+	for _, b := range g.Bullets {
+		if b.From == "enemy" {
+			bullets = append(bullets, b)
+		}
+	}
+	sort.SliceStable(bullets, func(i, j int) bool {
+		return math.Hypot(bullets[i].X-p.X, bullets[i].Y-chest) < math.Hypot(bullets[j].X-p.X, bullets[j].Y-chest)
+	})
+	for i := range gymBulletSlots {
+		if i >= len(bullets) {
+			obs = append(obs, 0, 0, 0, 0, 0)
+			continue
+		}
+		b := bullets[i]
+		obs = append(obs, 1, (b.X-p.X)/screenWidth, (b.Y-chest)/screenHeight, b.Vx/500, b.Vy/500)
+	}
+	return obs
+}
@@ -0,0 +1,63 @@
+package sim
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// TestEnvsInParallel steps environments from separate goroutines; envs with
+// the same seed and actions must end up in the same place.
+func TestEnvsInParallel(t *testing.T) {
+	const envs = 4
+	results := make([]StepResult, envs)
+	var wg sync.WaitGroup
+	for i := range envs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e := NewEnv(0, 0)
+			r := e.Reset(uint64(i % 2))
+			for step := range 300 {
+				r = e.Step(Action{Move: 1 - step/100, Jump: step%30 < 10, Shoot: true})
+				if r.Done || r.Truncated {
+					break
+				}
+			}
+			results[i] = r
+		}()
+	}
+	wg.Wait()
+
+	for i := 2; i < envs; i++ {
+		if !reflect.DeepEqual(results[i], results[i-2]) {
+			t.Errorf("env %d = %+v, want %+v like env %d", i, results[i].Info, results[i-2].Info, i-2)
+		}
+	}
+}
+
+// TestWithoutLoading uses the package the way another program would, without
+// LoadScripts or LoadProjectiles: the embedded data must be enough.
+func TestWithoutLoading(t *testing.T) {
+	if len(embeddedScripts) == 0 || len(projectileDefs) == 0 {
+		t.Fatal("embedded scripts or projectiles not loaded by init")
+	}
+	e := NewEnv(0, 0)
+	if r := e.Reset(1); len(r.Observation) != ObsSize || r.Info.Level != 1 {
+		t.Fatalf("Reset = %+v, want level 1 and %d observations", r.Info, ObsSize)
+	}
+	for range 100 {
+		e.Step(Action{Move: 1, Shoot: true})
+	}
+
+	// A run without level scripts plays empty levels.
+	g := NewGameState()
+	g.Players["a"] = g.NewPlayer("a", "a")
+	g.NewRun(1, nil, nil)
+	for range 600 {
+		g.Step(TickDT)
+	}
+	if g.Level < 2 {
+		t.Errorf("level = %d after 10s without scripts, want the empty levels to pass", g.Level)
+	}
+}
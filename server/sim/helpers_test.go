@@ -0,0 +1,21 @@
+package sim
+
+import "testing"
+
+// newTestGame starts a seed 1 run of mode on the embedded scripts, with a
+// player for each of ids, and clears level 1's enemies, bullets and boxes so
+// that tests can place their own.
+func newTestGame(t testing.TB, mode string, rules Rules, ids ...string) *GameState {
+	t.Helper()
+	g := NewGameState()
+	g.Mode = mode
+	g.Rules = rules
+	for _, id := range ids {
+		g.Players[id] = g.NewPlayer(id, id)
+	}
+	g.NewRun(1, Scripts(), Projectiles())
+	g.Enemies = nil
+	g.Bullets = nil
+	g.Boxes = nil
+	return g
+}
@@ -0,0 +1,143 @@
+package sim
+
+const (
+	itemSize         = 20.0
+	itemDriftSpeed   = 60.0
+	itemLifetime     = 12.0
+	itemCollectTime  = 0.35
+	dropChance       = 0.25
+	bossDrops        = 3
+	itemBounceFactor = 0.4
+)
+
+type Item struct {
+	Kind      string  `json:"kind"`
+	X         float64 `json:"x"`
+	Y         float64 `json:"y"`
+	Vy        float64 `json:"vy"`
+	Collected bool    `json:"collected"`
+	Timer     float64 `json:"timer"`
+
+	floating bool
+}
+
+type fruitDef struct {
+	Points    int
+	Buff      string
+	Duration  float64
+	ExtraLife bool
+	Weight    int
+}
+
+var fruitDefs = map[string]fruitDef{
+	"apple":      {Points: 50, Weight: 5},
+	"bananas":    {Points: 100, Weight: 4},
+	"strawberry": {Points: 200, Weight: 2},
+	"cherries":   {Buff: "rapidFire", Duration: 8, Weight: 2},
+	"orange":     {Buff: "tripleShot", Duration: 8, Weight: 2},
+	"melon":      {Buff: "shield", Duration: 10, Weight: 2},
+	"kiwi":       {Buff: "doubleJump", Duration: 10, Weight: 2},
+	"pineapple":  {ExtraLife: true, Weight: 1},
+}
+
+// fruitOrder fixes the order of the weighted roll in randomFruit so that drops
+// only depend on the room seed.
+var fruitOrder = []string{"apple", "bananas", "strawberry", "cherries", "orange", "melon", "kiwi", "pineapple"}
+
+func (g *GameState) randomFruit() string {
+	total := 0
+	for _, name := range fruitOrder {
+		total += fruitDefs[name].Weight
+	}
+	n := g.rng.IntN(total)
+	for _, name := range fruitOrder {
+		if n -= fruitDefs[name].Weight; n < 0 {
+			return name
+		}
+	}
+	return fruitOrder[0]
+}
+
+func (g *GameState) spawnItem(kind string, x, y float64, floating bool) *Item {
+	item := &Item{Kind: kind, X: x, Y: y, floating: floating}
+	g.Items = append(g.Items, item)
+	return item
+}
+
+func (g *GameState) placeItems(defs []itemDef) {
+	for _, d := range defs {
+		g.spawnItem(d.Kind, d.X.roll(g.rng), float64(groundY)-itemSize/2-d.Height.roll(g.rng), true)
+	}
+}
+
+func (g *GameState) dropLoot(e *Enemy) {
+	drops := 0
+	if enemyKinds[e.Kind].Boss {
+		drops = bossDrops
+	} else if g.rng.Float64() < dropChance {
+		drops = 1
+	}
+	for i := 0; i < drops; i++ {
+		item := g.spawnItem(g.randomFruit(), e.X+float64(i)*25, e.Y-float64(playerHeight)/2, false)
+		item.Vy = -200
+	}
+}
+
+func (g *GameState) updateItems(dt float64) {
+	alive := g.Items[:0]
+	for _, it := range g.Items {
+		it.Timer += dt
+		if it.Collected {
+			if it.Timer < itemCollectTime {
+				alive = append(alive, it)
+			}
+			continue
+		}
+		it.X -= itemDriftSpeed * dt
+		if !it.floating {
+			it.Vy += gravity * dt
+			it.Y += it.Vy * dt
+			if floor := float64(groundY) - itemSize/2; it.Y > floor {
+				it.Y = floor
+				it.Vy = -it.Vy * itemBounceFactor
+			}
+		}
+		if it.X > -itemSize && it.Timer < itemLifetime {
+			alive = append(alive, it)
+		}
+	}
+	g.Items = alive
+}
+
+func itemBox(it *Item) aabb {
+	return aabb{X: it.X - itemSize/2, Y: it.Y - itemSize/2, W: itemSize, H: itemSize}
+}
+
+// collectItems hands every item a player overlaps to that player. It relies on
+// the player grid built by checkCollisions.
+func (g *GameState) collectItems() {
+	for _, it := range g.Items {
+		if it.Collected {
+			continue
+		}
+		box := itemBox(it)
+		g.playerGrid.query(box, func(p *Player, pBox aabb) {
+			if it.Collected || p.Lives <= 0 || !pBox.overlaps(box) {
+				return
+			}
+			it.Collected = true
+			it.Timer = 0
+			g.applyFruit(p, fruitDefs[it.Kind])
+		})
+	}
+}
+
+func (g *GameState) applyFruit(p *Player, def fruitDef) {
+	g.awardPoints(p, def.Points)
+	if def.Buff != "" {
+		p.Buffs[def.Buff] = def.Duration
+	}
+	if def.ExtraLife {
+		p.Lives = min(p.Lives+1, maxLives)
+	}
+}
@@ -0,0 +1,187 @@
+package sim
+
+const (
+	boxWidth      = 28.0
+	boxHeight     = 24.0
+	boxHitTime    = 0.3
+	boxBreakTime  = 0.4
+	flagWidth     = 30.0
+	flagHeight    = 64.0
+	flagPressTime = 0.6
+)
+
+// boxHP is the number of hits each box sprite takes to break.
+var boxHP = map[string]int{
+	"box1": 1,
+	"box2": 3,
+	"box3": 5,
+}
+
+type Box struct {
+	Kind   string  `json:"kind"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	HP     int     `json:"hp"`
+	Hit    float64 `json:"hit"`
+	Broken bool    `json:"broken"`
+	Timer  float64 `json:"timer"`
+
+	drop string
+}
+
+type Flag struct {
+	Kind    string  `json:"kind"`
+	X       float64 `json:"x"`
+	Reached bool    `json:"reached"`
+	Open    bool    `json:"open"`
+	Timer   float64 `json:"timer"`
+}
+
+type boxDef struct {
+	Line   int
+	Kind   string
+	X      floatRange
+	Height floatRange
+	Drop   string
+}
+
+type flagDef struct {
+	Line int
+	Kind string
+	X    float64
+}
+
+func (g *GameState) placeBoxes(defs []boxDef) {
+	for _, d := range defs {
+		hp := boxHP[d.Kind]
+		g.Boxes = append(g.Boxes, &Box{
+			Kind: d.Kind,
+			X:    d.X.roll(g.rng),
+			Y:    float64(groundY) - boxHeight - d.Height.roll(g.rng),
+			HP:   hp,
+			drop: d.Drop,
+		})
+	}
+}
+
+func (g *GameState) placeFlags(defs []flagDef) {
+	g.Flags = g.Flags[:0]
+	for _, d := range defs {
+		g.Flags = append(g.Flags, &Flag{Kind: d.Kind, X: d.X, Open: d.Kind != "end"})
+	}
+}
+
+// startX is where players enter the current level: its start flag, or the
+// classic fixed position for levels without one.
+func (g *GameState) startX() float64 {
+	for _, f := range g.Flags {
+		if f.Kind == "start" {
+			return f.X
+		}
+	}
+	return float64(playerX)
+}
+
+func (g *GameState) endFlag() *Flag {
+	for _, f := range g.Flags {
+		if f.Kind == "end" {
+			return f
+		}
+	}
+	return nil
+}
+
+func boxBox(b *Box) aabb {
+	return aabb{X: b.X - boxWidth/2, Y: b.Y, W: boxWidth, H: boxHeight}
+}
+
+func flagBox(f *Flag) aabb {
+	return aabb{X: f.X - flagWidth/2, Y: float64(groundY) - flagHeight, W: flagWidth, H: flagHeight}
+}
+
+func (g *GameState) updateLevelObjects(dt float64) {
+	alive := g.Boxes[:0]
+	for _, b := range g.Boxes {
+		b.Hit = max(b.Hit-dt, 0)
+		if b.Broken {
+			b.Timer += dt
+			if b.Timer >= boxBreakTime {
+				continue
+			}
+		}
+		alive = append(alive, b)
+	}
+	g.Boxes = alive
+
+	for _, f := range g.Flags {
+		if f.Reached {
+			f.Timer += dt
+		}
+	}
+}
+
+func (g *GameState) damageBox(b *Box, damage int) {
+	b.HP -= damage
+	b.Hit = boxHitTime
+	if b.HP > 0 {
+		return
+	}
+	b.Broken = true
+	b.Timer = 0
+	drop := b.drop
+	if drop == "" {
+		drop = g.randomFruit()
+	}
+	item := g.spawnItem(drop, b.X, b.Y, false)
+	item.Vy = -250
+}
+
+// hitBoxes lets player bullets break boxes. Boxes always stop the bullet.
+func (g *GameState) hitBoxes() {
+	for _, bullet := range g.Bullets {
+		if bullet.From != "player" || bullet.spent {
+			continue
+		}
+		box, dx, dy := g.bulletSweep(bullet)
+		for _, b := range g.Boxes {
+			if b.Broken {
+				continue
+			}
+			if _, hit := box.sweep(dx, dy, boxBox(b)); !hit {
+				continue
+			}
+			g.damageBox(b, g.projectiles[bullet.Type].Damage)
+			bullet.spent = true
+			break
+		}
+	}
+}
+
+// touchFlags moves the respawn point of each player who touches a checkpoint
+// and completes the level once a player reaches an open end flag.
+func (g *GameState) touchFlags() {
+	for _, f := range g.Flags {
+		box := flagBox(f)
+		g.playerGrid.query(box, func(p *Player, pBox aabb) {
+			if p.Lives <= 0 || !pBox.overlaps(box) {
+				return
+			}
+			switch f.Kind {
+			case "checkpoint":
+				if f.X > p.RespawnX {
+					p.RespawnX = f.X
+				}
+				if !f.Reached {
+					f.Reached = true
+					f.Timer = 0
+				}
+			case "end":
+				if f.Open && !f.Reached && g.Intermission <= 0 {
+					f.Reached = true
+					f.Timer = 0
+					g.completeLevel()
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package sim
+
+// GameMode is the rules of a match. The simulation calls the hooks at fixed
+// points and leaves the decisions to the mode: OnStart when a run begins
+// (after the players are reset, before the first level), OnLevelStart once a
+// level is placed, OnLevelComplete once its goal is met, OnTick every tick
+// after the players move, OnPlayerJoin for players dropping into a running
+// match, OnKill when a player's bullet kills an enemy, OnPlayerDown when a
+// player loses their last life, and CheckWinCondition at the end of every
+// tick to decide whether the match is over. Every hook gets the match it
+// runs on; modes keep their state there so that it is part of snapshots and
+// replays.
+type GameMode interface {
+	OnStart(g *GameState)
+	OnLevelStart(g *GameState)
+	OnLevelComplete(g *GameState)
+	OnTick(g *GameState, dt float64)
+	OnPlayerJoin(g *GameState, p *Player)
+	OnKill(g *GameState, killer *Player, e *Enemy)
+	OnPlayerDown(g *GameState, p *Player)
+	CheckWinCondition(g *GameState) bool
+}
+
+const (
+	ModeCoop       = "coop"
+	ModeTimeAttack = "timeattack"
+	ModeEndless    = "endless"
+	ModeVersus     = "versus"
+
+	respawnTime          = 5.0
+	timeAttackTime       = 180.0
+	timeAttackLevelBonus = 30.0
+	timeAttackKillBonus  = 1.0
+	timeAttackDeathCost  = 15.0
+)
+
+// gameModes lists the modes a room can be created with, by the name clients
+// use. Co-op survival is the default.
+var gameModes = map[string]GameMode{
+	ModeCoop:       survivalMode{},
+	ModeTimeAttack: timeAttackMode{},
+	ModeEndless:    endlessMode{},
+	ModeVersus:     versusMode{},
+}
+
+// currentMode returns the rules of the match.
+func (g *GameState) currentMode() GameMode {
+	if m, ok := gameModes[g.Mode]; ok {
+		return m
+	}
+	return survivalMode{}
+}
+
+// ValidMode reports whether name is a game mode a room can use.
+func ValidMode(name string) bool {
+	return gameModes[name] != nil
+}
+
+func (g *GameState) versus() bool {
+	return g.Mode == ModeVersus
+}
+
+// survivalMode is the classic co-op game: levels of enemy waves, 100 points
+// a kill, downed players can be revived and the match ends once nobody is
+// left standing.
+type survivalMode struct{}
+
+func (survivalMode) OnStart(g *GameState) {}
+
+func (survivalMode) OnLevelStart(g *GameState) {
+	g.reviveAll()
+}
+
+func (survivalMode) OnLevelComplete(g *GameState) {}
+
+func (survivalMode) OnTick(g *GameState, dt float64) {
+	g.updateRevives(dt)
+	g.updateWaves(dt)
+}
+
+func (survivalMode) OnPlayerJoin(g *GameState, p *Player) {}
+
+func (survivalMode) OnKill(g *GameState, killer *Player, e *Enemy) {
+	g.creditKill(killer)
+}
+
+func (survivalMode) OnPlayerDown(g *GameState, p *Player) {
+	downPlayer(p)
+}
+
+func (survivalMode) CheckWinCondition(g *GameState) bool {
+	return len(g.Players) > 0 && !g.anyoneStanding()
+}
+
+// timeAttackMode plays the same levels against the clock. Kills and cleared
+// levels add time, losing the last life costs time and brings the player
+// back after respawnTime, and the match ends when the clock runs out. The
+// clock stops between levels.
+type timeAttackMode struct{}
+
+func (timeAttackMode) OnStart(g *GameState) {
+	g.Clock = timeAttackTime
+}
+
+func (timeAttackMode) OnLevelStart(g *GameState) {}
+
+// OnLevelComplete adds the level bonus. Levels the host skips to don't
+// count.
+func (timeAttackMode) OnLevelComplete(g *GameState) {
+	g.Clock += timeAttackLevelBonus
+}
+
+func (timeAttackMode) OnTick(g *GameState, dt float64) {
+	if g.Intermission <= 0 {
+		g.Clock = max(g.Clock-dt, 0)
+	}
+	g.updateRespawns(dt)
+	g.updateWaves(dt)
+}
+
+func (timeAttackMode) OnPlayerJoin(g *GameState, p *Player) {}
+
+func (timeAttackMode) OnKill(g *GameState, killer *Player, e *Enemy) {
+	g.creditKill(killer)
+	g.Clock += timeAttackKillBonus
+}
+
+func (timeAttackMode) OnPlayerDown(g *GameState, p *Player) {
+	p.Respawn = respawnTime
+	g.Clock = max(g.Clock-timeAttackDeathCost, 0)
+}
+
+func (timeAttackMode) CheckWinCondition(g *GameState) bool {
+	return g.Clock <= 0
+}
+
+// endlessMode never ends: the levels keep getting harder and players who
+// lose their last life come back after respawnTime.
+type endlessMode struct{}
+
+func (endlessMode) OnStart(g *GameState) {}
+
+func (endlessMode) OnLevelStart(g *GameState) {}
+
+func (endlessMode) OnLevelComplete(g *GameState) {}
+
+func (endlessMode) OnTick(g *GameState, dt float64) {
+	g.updateRespawns(dt)
+	g.updateWaves(dt)
+}
+
+func (endlessMode) OnPlayerJoin(g *GameState, p *Player) {}
+
+func (endlessMode) OnKill(g *GameState, killer *Player, e *Enemy) {
+	g.creditKill(killer)
+}
+
+func (endlessMode) OnPlayerDown(g *GameState, p *Player) {
+	p.Respawn = respawnTime
+}
+
+func (endlessMode) CheckWinCondition(g *GameState) bool {
+	return false
+}
+
+// updateRespawns brings back players whose respawn timer ran out, at their
+// last checkpoint with a fresh set of lives.
+func (g *GameState) updateRespawns(dt float64) {
+	for _, p := range g.SortedPlayers() {
+		if p.Respawn <= 0 {
+			continue
+		}
+		if p.Respawn -= dt; p.Respawn <= 0 {
+			p.Respawn = 0
+			p.Lives = startLives
+			p.X = p.RespawnX
+			p.Y = -float64(playerHeight)
+			p.Vx, p.Vy = 0, 0
+			p.Invulnerable = invulnerableTime
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package sim
+
+import "testing"
+
+// TestLevelStartKeepsScore starts matches on level 2 the way a room does,
+// which places level 1 first.
+func TestLevelStartKeepsScore(t *testing.T) {
+	g := newTestGame(t, ModeTimeAttack, Rules{})
+	g.StartLevel(2)
+	if g.Clock != timeAttackTime {
+		t.Errorf("time attack clock = %v after skipping a level, want %v", g.Clock, timeAttackTime)
+	}
+	g.completeLevel()
+	if want := timeAttackTime + timeAttackLevelBonus; g.Clock != want {
+		t.Errorf("time attack clock = %v after completing a level, want %v", g.Clock, want)
+	}
+
+	g = newTestGame(t, ModeVersus, Rules{}, "a", "b", "c")
+	g.StartLevel(2)
+	if got := g.Versus.Round; got != 1 {
+		t.Errorf("versus round = %d after changing level, want 1", got)
+	}
+}
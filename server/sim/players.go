@@ -0,0 +1,296 @@
+package sim
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	startLives        = 3
+	maxLives          = 5
+	invulnerableTime  = 1.5
+	shootCooldown     = 0.4
+	rapidFireCooldown = 0.15
+	playerSpeed       = 200.0
+	playerAccel       = 1500.0
+	coyoteTime        = 0.1
+	jumpBufferTime    = 0.12
+	jumpCutFactor     = 0.45
+	wallSlideSpeed    = 90.0
+	wallJumpVx        = 260.0
+	wallJumpLock      = 0.18
+)
+
+// Movement states reported in snapshots so clients can pick an animation.
+const (
+	stateIdle       = "idle"
+	stateRun        = "run"
+	stateJump       = "jump"
+	stateDoubleJump = "doubleJump"
+	stateFall       = "fall"
+	stateWallSlide  = "wallSlide"
+)
+
+func (g *GameState) NewPlayer(id, name string) *Player {
+	x := g.startX()
+	return &Player{
+		ID:       id,
+		Name:     name,
+		X:        x,
+		Y:        float64(groundY),
+		Vy:       0,
+		Facing:   1,
+		Lives:    startLives,
+		Buffs:    map[string]float64{},
+		RespawnX: x,
+		State:    stateIdle,
+	}
+}
+
+// AddPlayer adds a player to a running match and lets the mode place them.
+func (g *GameState) AddPlayer(id, name string, bot bool) *Player {
+	p := g.NewPlayer(id, name)
+	p.Bot = bot
+	g.Players[id] = p
+	g.currentMode().OnPlayerJoin(g, p)
+	return p
+}
+
+// Dir is the direction p is holding: -1, 0 or 1.
+func (p *Player) Dir() int {
+	return p.dir
+}
+
+// resetPlayer gives p a fresh start for a new run: they drop in from the top
+// of the screen with nothing carried over but who they are and the direction
+// they are holding, so a run only depends on its seed and inputs.
+func (g *GameState) resetPlayer(p *Player) {
+	dir, bot := p.dir, p.Bot
+	*p = *g.NewPlayer(p.ID, p.Name)
+	p.Bot = bot
+	p.Y = -float64(playerHeight)
+	p.dir = dir
+}
+
+// SortedPlayers returns the players in ID order. Code where the order
+// players are handled in decides an outcome, such as who grabs an item two
+// players touch at once, goes through here so replays stay deterministic.
+func (g *GameState) SortedPlayers() []*Player {
+	ps := make([]*Player, 0, len(g.Players))
+	for _, p := range g.Players {
+		ps = append(ps, p)
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i].ID < ps[j].ID })
+	return ps
+}
+
+func (g *GameState) updatePlayers(dt float64) {
+	for _, p := range g.SortedPlayers() {
+		if p.standing != nil {
+			p.X += p.standing.dx
+			p.Y += p.standing.dy
+		}
+
+		surf := g.surfaceUnder(p)
+		if p.grounded {
+			surf.Grip *= g.currentWeather().Grip
+		}
+		target := 0.0
+		if p.Lives > 0 {
+			target = float64(p.dir) * playerSpeed * surf.MaxSpeed
+		}
+		// Right after a wall jump the stick is ignored so that holding
+		// towards the wall doesn't cancel the push-off.
+		if p.wallLock > 0 {
+			p.wallLock -= dt
+		} else {
+			step := playerAccel * surf.Grip * dt
+			p.Vx += max(-step, min(target-p.Vx, step))
+			if target != 0 {
+				p.Facing = p.dir
+			}
+		}
+		p.X += p.Vx * dt
+		if !p.grounded {
+			p.X += g.Weather.Wind * windDrift * dt
+		}
+		p.wallDir = 0
+		if minX, maxX := float64(playerWidth)/2, float64(screenWidth)-float64(playerWidth)/2; p.X <= minX || p.X >= maxX {
+			p.X = max(minX, min(p.X, maxX))
+			p.Vx = 0
+			p.wallDir = -1
+			if p.X >= maxX {
+				p.wallDir = 1
+			}
+		}
+
+		prevY := p.Y
+		p.Vy += gravity * dt
+		if p.wallSliding() {
+			p.Vy = min(p.Vy, wallSlideSpeed)
+		}
+		p.Y += p.Vy * dt
+		p.standing = nil
+		p.grounded = g.landOnTraps(p, prevY)
+		if p.Y > float64(groundY) {
+			p.Y = float64(groundY)
+			p.Vy = 0
+			p.grounded = true
+		}
+		if p.grounded {
+			p.airJumps = 0
+			p.coyote = coyoteTime
+			p.jumping = false
+		} else {
+			p.coyote = max(p.coyote-dt, 0)
+		}
+		if p.Vy >= 0 {
+			p.jumping = false
+		}
+		if p.jumpBuffer > 0 {
+			p.jumpBuffer = max(p.jumpBuffer-dt, 0)
+			if p.jumpBuffer > 0 {
+				tryJump(p, false)
+			}
+		}
+		p.State = movementState(p)
+
+		p.Invulnerable = max(p.Invulnerable-dt, 0)
+		p.shootCooldown = max(p.shootCooldown-dt, 0)
+		updateCombo(p, dt)
+		for buff, left := range p.Buffs {
+			if left -= dt; left <= 0 {
+				delete(p.Buffs, buff)
+			} else {
+				p.Buffs[buff] = left
+			}
+		}
+	}
+}
+
+// wallSliding reports whether p is falling while pushing against the wall
+// they touch.
+func (p *Player) wallSliding() bool {
+	return !p.grounded && p.wallDir != 0 && p.dir == p.wallDir && p.Vy > 0
+}
+
+func movementState(p *Player) string {
+	switch {
+	case p.grounded && p.Vx != 0:
+		return stateRun
+	case p.grounded:
+		return stateIdle
+	case p.wallSliding():
+		return stateWallSlide
+	case p.Vy < 0 && p.airJumps > 0:
+		return stateDoubleJump
+	case p.Vy < 0:
+		return stateJump
+	}
+	return stateFall
+}
+
+// maxAirJumps is how many extra jumps p can make before landing: one, or two
+// while the doubleJump buff lasts.
+func maxAirJumps(p *Player) int {
+	if p.Buffs["doubleJump"] > 0 {
+		return 2
+	}
+	return 1
+}
+
+// playerJump handles a jump press. A press that can't jump right away is
+// remembered for jumpBufferTime, so pressing just before landing or touching a
+// wall still jumps.
+func playerJump(p *Player) {
+	if p.Lives <= 0 {
+		return
+	}
+	p.jumpBuffer = jumpBufferTime
+	tryJump(p, true)
+}
+
+// playerJumpRelease cuts a jump short when the button is let go while the
+// player is still rising from it, giving lower hops on short taps.
+func playerJumpRelease(p *Player) {
+	p.jumpBuffer = 0
+	if p.jumping && p.Vy < 0 {
+		p.Vy *= jumpCutFactor
+	}
+	p.jumping = false
+}
+
+// tryJump jumps from the ground (or just after leaving it), off a wall, or,
+// when air is set, in mid-air. Buffered presses never spend an air jump.
+func tryJump(p *Player, air bool) {
+	switch {
+	case p.grounded || p.coyote > 0:
+		p.Vy = jumpImpulse
+		p.standing = nil
+	case p.wallDir != 0:
+		p.Vy = jumpImpulse
+		p.Vx = -float64(p.wallDir) * wallJumpVx
+		p.Facing = -p.wallDir
+		p.wallLock = wallJumpLock
+	case air && p.airJumps < maxAirJumps(p):
+		p.airJumps++
+		p.Vy = jumpImpulse
+	default:
+		return
+	}
+	p.grounded = false
+	p.coyote = 0
+	p.jumpBuffer = 0
+	p.jumping = true
+}
+
+func (g *GameState) playerShoot(p *Player, m Input) {
+	if p.Lives <= 0 || p.shootCooldown > 0 {
+		return
+	}
+	p.shootCooldown = shootCooldown
+	if p.Buffs["rapidFire"] > 0 {
+		p.shootCooldown = rapidFireCooldown
+	}
+
+	x, y := p.X, p.Y-float64(playerHeight)/2
+	angle := 0.0
+	if p.Facing < 0 {
+		angle = math.Pi
+	}
+	if m.AimX != 0 || m.AimY != 0 {
+		angle = math.Atan2(m.AimY-y, m.AimX-x)
+	}
+	shots := 1
+	if p.Buffs["tripleShot"] > 0 {
+		shots = 3
+	}
+	for _, b := range g.fireSpread("player", "player", x, y, angle, shots, 0.15) {
+		b.Owner = p.ID
+	}
+	p.Stats.Shots += shots
+}
+
+// damagePlayer applies one hit to p. Shields absorb the hit, and a player who
+// was just hit is briefly invulnerable. A player with lives left drops back in
+// at their last checkpoint; what happens to one without is up to the mode.
+func (g *GameState) damagePlayer(p *Player) {
+	if p.Lives <= 0 || p.Invulnerable > 0 {
+		return
+	}
+	p.Invulnerable = invulnerableTime
+	p.Stats.DamageTaken++
+	if p.Buffs["shield"] > 0 {
+		delete(p.Buffs, "shield")
+		return
+	}
+	p.Lives--
+	p.Stats.Deaths++
+	if p.Lives > 0 {
+		p.X = p.RespawnX
+		p.Y = -float64(playerHeight)
+		p.Vy = 0
+		return
+	}
+	g.currentMode().OnPlayerDown(g, p)
+}
@@ -0,0 +1,210 @@
+package sim
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+// ProjectileDef is one entry of the projectile table: how fast a projectile
+// flies, how gravity and homing bend it, how long it lives, how many enemies
+// it goes through, its size and the damage it does.
+type ProjectileDef struct {
+	Speed    float64 `json:"speed"`
+	Gravity  float64 `json:"gravity"`
+	TurnRate float64 `json:"turnRate"`
+	Lifetime float64 `json:"lifetime"`
+	Pierce   int     `json:"pierce"`
+	Radius   float64 `json:"radius"`
+	Damage   int     `json:"damage"`
+}
+
+//go:embed projectiles.json
+var defaultProjectiles []byte
+
+var projectileDefs map[string]ProjectileDef
+
+// The embedded table is loaded up front so the package works without
+// LoadProjectiles.
+func init() {
+	if err := LoadProjectiles(""); err != nil {
+		panic(fmt.Sprint("tabela de projéteis embutida inválida: ", err))
+	}
+}
+
+// LoadProjectiles reads the projectile table from path, or the embedded
+// default table when path is empty.
+func LoadProjectiles(path string) error {
+	data := defaultProjectiles
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return err
+		}
+	}
+	defs := map[string]ProjectileDef{}
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return err
+	}
+	for _, name := range requiredProjectiles() {
+		if _, ok := defs[name]; !ok {
+			return fmt.Errorf("projétil %q não definido", name)
+		}
+	}
+	for name, d := range defs {
+		if d.Speed <= 0 || d.Radius <= 0 {
+			return fmt.Errorf("projétil %q: speed e radius devem ser positivos", name)
+		}
+		if d.Damage < 1 {
+			d.Damage = 1
+			defs[name] = d
+		}
+	}
+	projectileDefs = defs
+	return nil
+}
+
+// Projectiles returns the table loaded by LoadProjectiles, the embedded one
+// until then. Runs keep the table they started with (see NewRun).
+func Projectiles() map[string]ProjectileDef {
+	return projectileDefs
+}
+
+// requiredProjectiles lists, sorted, every projectile type the simulation
+// fires: the players', the enemy kinds' and the bosses'.
+func requiredProjectiles() []string {
+	seen := map[string]bool{"player": true}
+	for _, k := range enemyKinds {
+		if k.Projectile != "" {
+			seen[k.Projectile] = true
+		}
+	}
+	for _, name := range bossProjectiles {
+		seen[name] = true
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (g *GameState) fireProjectile(typ, from string, x, y, angle float64) *Bullet {
+	def := g.projectiles[typ]
+	b := &Bullet{
+		X:      x,
+		Y:      y,
+		prevX:  x,
+		prevY:  y,
+		Vx:     math.Cos(angle) * def.Speed,
+		Vy:     math.Sin(angle) * def.Speed,
+		From:   from,
+		Type:   typ,
+		pierce: def.Pierce,
+	}
+	g.Bullets = append(g.Bullets, b)
+	return b
+}
+
+// fireAimed shoots at (tx, ty). Projectiles affected by gravity are launched
+// on the arc that lands on the target after travelling at their nominal speed.
+func (g *GameState) fireAimed(typ, from string, x, y, tx, ty float64) *Bullet {
+	def := g.projectiles[typ]
+	if def.Gravity == 0 {
+		return g.fireProjectile(typ, from, x, y, math.Atan2(ty-y, tx-x))
+	}
+	dx, dy := tx-x, ty-y
+	t := math.Max(math.Abs(dx)/def.Speed, 0.3)
+	b := g.fireProjectile(typ, from, x, y, 0)
+	b.Vx = dx / t
+	b.Vy = dy/t - 0.5*def.Gravity*t
+	return b
+}
+
+func (g *GameState) fireSpread(typ, from string, x, y, angle float64, n int, step float64) []*Bullet {
+	bullets := make([]*Bullet, n)
+	for i := range bullets {
+		bullets[i] = g.fireProjectile(typ, from, x, y, angle+(float64(i)-float64(n-1)/2)*step)
+	}
+	return bullets
+}
+
+// updateBullets moves every bullet. Bullets that leave the screen or run
+// out of time are only removed by cullBullets, after checkCollisions has
+// swept their last move.
+func (g *GameState) updateBullets(dt float64) {
+	for _, b := range g.Bullets {
+		def := g.projectiles[b.Type]
+		b.age += dt
+		b.prevX, b.prevY = b.X, b.Y
+		if def.TurnRate > 0 {
+			g.steerBullet(b, def, dt)
+		}
+		b.Vy += def.Gravity * dt
+		b.Vx += g.Weather.Wind * dt
+		b.X += b.Vx * dt
+		b.Y += b.Vy * dt
+	}
+}
+
+// cullBullets removes the bullets that were spent, expired, hit the ground
+// or left the screen this tick.
+func (g *GameState) cullBullets() {
+	alive := g.Bullets[:0]
+	for _, b := range g.Bullets {
+		def := g.projectiles[b.Type]
+		if b.spent {
+			continue
+		}
+		if def.Lifetime > 0 && b.age >= def.Lifetime {
+			continue
+		}
+		if def.Gravity > 0 && b.Y >= float64(groundY) {
+			continue
+		}
+		if b.X > 0 && b.X < float64(screenWidth) && b.Y > -float64(screenHeight) && b.Y < float64(screenHeight) {
+			alive = append(alive, b)
+		}
+	}
+	g.Bullets = alive
+}
+
+// steerBullet turns a homing projectile towards its target by at most
+// TurnRate radians per second, keeping its speed.
+func (g *GameState) steerBullet(b *Bullet, def ProjectileDef, dt float64) {
+	tx, ty, ok := g.homingTarget(b)
+	if !ok {
+		return
+	}
+	speed := math.Hypot(b.Vx, b.Vy)
+	cur := math.Atan2(b.Vy, b.Vx)
+	diff := math.Remainder(math.Atan2(ty-b.Y, tx-b.X)-cur, 2*math.Pi)
+	turn := def.TurnRate * dt
+	diff = math.Max(-turn, math.Min(turn, diff))
+	b.Vx = math.Cos(cur+diff) * speed
+	b.Vy = math.Sin(cur+diff) * speed
+}
+
+func (g *GameState) homingTarget(b *Bullet) (x, y float64, ok bool) {
+	if b.From == "enemy" {
+		p := g.targetPlayer()
+		if p == nil {
+			return 0, 0, false
+		}
+		return p.X, p.Y - float64(playerHeight)/2, true
+	}
+	best := math.Inf(1)
+	for _, e := range g.Enemies {
+		if e.Dead {
+			continue
+		}
+		if d := math.Hypot(e.X-b.X, e.Y-b.Y); d < best {
+			best, x, y, ok = d, e.X, e.Y, true
+		}
+	}
+	return x, y, ok
+}
@@ -0,0 +1,70 @@
+package sim
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadProjectiles(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		err  string
+	}{
+		{
+			name: "complete",
+			json: `{"player":{"speed":1,"radius":1},"enemy":{"speed":1,"radius":1},"seed":{"speed":1,"radius":1},"bark":{"speed":1,"radius":1},"stinger":{"speed":1,"radius":1}}`,
+		},
+		{
+			name: "missing enemy kind projectile",
+			json: `{"player":{"speed":1,"radius":1},"enemy":{"speed":1,"radius":1},"bark":{"speed":1,"radius":1},"stinger":{"speed":1,"radius":1}}`,
+			err:  `projétil "seed" não definido`,
+		},
+		{
+			name: "missing boss projectile",
+			json: `{"player":{"speed":1,"radius":1},"enemy":{"speed":1,"radius":1},"seed":{"speed":1,"radius":1},"bark":{"speed":1,"radius":1}}`,
+			err:  `projétil "stinger" não definido`,
+		},
+	}
+	defer LoadProjectiles("")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "projectiles.json")
+			if err := os.WriteFile(path, []byte(tt.json), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			err := LoadProjectiles(path)
+			if got := errString(err); got != tt.err {
+				t.Errorf("LoadProjectiles = %q, want %q", got, tt.err)
+			}
+		})
+	}
+}
+
+// TestBossProjectiles checks that bossProjectiles lists every type the boss
+// attacks fire.
+func TestBossProjectiles(t *testing.T) {
+	g := newTestGame(t, ModeCoop, Rules{})
+	boss := g.spawnEnemy("boss", 0)
+	for _, def := range bossDefs {
+		for _, phase := range def.Phases {
+			for _, attack := range phase.Attacks {
+				g.bossAttack(boss, attack)
+			}
+		}
+	}
+	for _, b := range g.Bullets {
+		if !slices.Contains(bossProjectiles, b.Type) {
+			t.Errorf("boss fired %q, missing from bossProjectiles", b.Type)
+		}
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,81 @@
+package sim
+
+import "math"
+
+// A player who loses their last life while a teammate is still standing goes
+// down instead of dying: they lie where they fell with a revive marker, and
+// teammates who stay next to them for reviveTime bring them back with
+// reviveLives. If nobody does before bleedOutTime runs out they are out,
+// watching the others until the next level starts.
+const (
+	bleedOutTime = 20.0
+	reviveTime   = 3.0
+	reviveRadius = 60.0
+	reviveLives  = 1
+)
+
+// downPlayer lays p down to wait for a revive.
+func downPlayer(p *Player) {
+	p.Downed = bleedOutTime
+	p.Revive = 0
+	p.Vx = 0
+	p.dir = 0
+	p.jumpBuffer = 0
+	p.Buffs = map[string]float64{}
+}
+
+func (g *GameState) anyoneStanding() bool {
+	for _, p := range g.Players {
+		if p.Lives > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// updateRevives advances the revive and bleed-out timers of downed players.
+// Revive progress only builds while a standing teammate is in range, fades
+// when they walk away, and holds the bleed-out clock while it builds.
+func (g *GameState) updateRevives(dt float64) {
+	for _, p := range g.SortedPlayers() {
+		if p.Downed <= 0 {
+			continue
+		}
+		var reviver *Player
+		for _, other := range g.SortedPlayers() {
+			if other.Lives > 0 && math.Hypot(other.X-p.X, other.Y-p.Y) <= reviveRadius {
+				reviver = other
+				break
+			}
+		}
+		if reviver == nil {
+			p.Revive = max(p.Revive-dt, 0)
+			if p.Downed -= dt; p.Downed <= 0 {
+				p.Downed, p.Revive = 0, 0
+				p.Out = true
+			}
+			continue
+		}
+		if p.Revive += dt; p.Revive >= reviveTime {
+			revivePlayer(p)
+			reviver.Stats.Revives++
+		}
+	}
+}
+
+// reviveAll brings back every downed or out player, as a new level starts.
+func (g *GameState) reviveAll() {
+	for _, p := range g.Players {
+		if p.Downed > 0 || p.Out {
+			revivePlayer(p)
+		}
+	}
+}
+
+// revivePlayer puts a downed or out player back on their feet.
+func revivePlayer(p *Player) {
+	p.Lives = reviveLives
+	p.Downed, p.Revive = 0, 0
+	p.Out = false
+	p.Invulnerable = invulnerableTime
+}
@@ -0,0 +1,112 @@
+package sim
+
+import "sort"
+
+const (
+	killPoints  = 100
+	comboWindow = 2.0
+	maxCombo    = 5
+)
+
+// PlayerStats are one player's numbers for the current run. DamageTaken
+// counts every hit received, including those a shield absorbed; Deaths only
+// the ones that cost a life.
+type PlayerStats struct {
+	Score       int     `json:"score"`
+	Kills       int     `json:"kills"`
+	Deaths      int     `json:"deaths"`
+	Shots       int     `json:"shots"`
+	Hits        int     `json:"hits"`
+	DamageTaken int     `json:"damageTaken"`
+	Revives     int     `json:"revives"`
+	Combo       int     `json:"combo"`
+	BestCombo   int     `json:"bestCombo"`
+	ComboTimer  float64 `json:"comboTimer"`
+}
+
+// Accuracy is the share of shots that hit at least one enemy.
+func (s PlayerStats) Accuracy() float64 {
+	if s.Shots == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Shots)
+}
+
+// ScoreLine is one row of the end-of-match scoreboard.
+type ScoreLine struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Team int    `json:"team,omitempty"`
+	PlayerStats
+	Accuracy float64 `json:"accuracy"`
+}
+
+// Scoreboard is sent once to every client when a match ends.
+type Scoreboard struct {
+	Type    string      `json:"type"`
+	Points  int         `json:"points"`
+	Level   int         `json:"level"`
+	Players []ScoreLine `json:"players"`
+	Versus  *Versus     `json:"versus,omitempty"`
+}
+
+// awardPoints adds points to the shared total and, when the points were
+// earned by a player who is still connected, to that player's score.
+func (g *GameState) awardPoints(p *Player, points int) {
+	g.Points += points
+	if p != nil {
+		p.Stats.Score += points
+	}
+}
+
+// creditKill counts a kill for p and awards its points. Kills made within
+// comboWindow of the previous one raise the combo, which multiplies the points
+// up to maxCombo.
+func (g *GameState) creditKill(p *Player) {
+	if p == nil {
+		g.awardPoints(nil, killPoints)
+		return
+	}
+	s := &p.Stats
+	s.Kills++
+	s.Combo++
+	s.ComboTimer = comboWindow
+	s.BestCombo = max(s.BestCombo, s.Combo)
+	g.awardPoints(p, killPoints*min(s.Combo, maxCombo))
+}
+
+func updateCombo(p *Player, dt float64) {
+	if p.Stats.ComboTimer <= 0 {
+		return
+	}
+	if p.Stats.ComboTimer -= dt; p.Stats.ComboTimer <= 0 {
+		p.Stats.ComboTimer = 0
+		p.Stats.Combo = 0
+	}
+}
+
+func (g *GameState) bulletOwner(b *Bullet) *Player {
+	if b.Owner == "" {
+		return nil
+	}
+	return g.Players[b.Owner]
+}
+
+// Scoreboard ranks the players by score, then by kills, then by ID.
+func (g *GameState) Scoreboard() Scoreboard {
+	board := Scoreboard{Type: "scoreboard", Points: g.Points, Level: g.Level, Versus: g.Versus}
+	for id, p := range g.Players {
+		board.Players = append(board.Players, ScoreLine{ID: id, Name: p.Name, Team: p.Team, PlayerStats: p.Stats, Accuracy: p.Stats.Accuracy()})
+	}
+	sort.Slice(board.Players, func(i, j int) bool {
+		a, b := board.Players[i], board.Players[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Kills != b.Kills {
+			return a.Kills > b.Kills
+		}
+		return a.ID < b.ID
+	})
+	return board
+}
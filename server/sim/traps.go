@@ -0,0 +1,262 @@
+package sim
+
+import "math"
+
+const (
+	platformWidth     = 64.0
+	platformHeight    = 8.0
+	fallingWidth      = 32.0
+	fallingHeight     = 10.0
+	fallingShakeTime  = 0.5
+	fallingRespawn    = 3.0
+	fireWidth         = 16.0
+	fireHeight        = 32.0
+	fireWarnTime      = 0.5
+	sawSize           = 38.0
+	trampolineSize    = 28.0
+	trampolineImpulse = -650.0
+	trampolineAnim    = 0.4
+	spikesHeight      = 16.0
+)
+
+// surfaceDef says how a surface changes player movement: Grip scales how fast
+// the player speeds up and slows down, MaxSpeed scales the top speed.
+type surfaceDef struct {
+	Grip     float64
+	MaxSpeed float64
+}
+
+var surfaceDefs = map[string]surfaceDef{
+	"":     {Grip: 1, MaxSpeed: 1},
+	"ice":  {Grip: 0.08, MaxSpeed: 1.2},
+	"mud":  {Grip: 1, MaxSpeed: 0.45},
+	"sand": {Grip: 0.6, MaxSpeed: 0.7},
+}
+
+// Trap is any level hazard or moving piece of terrain. Solid traps
+// (platforms, falling platforms and trampolines) can be stood on from above.
+type Trap struct {
+	Kind    string  `json:"kind"`
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+	W       float64 `json:"w"`
+	H       float64 `json:"h"`
+	State   string  `json:"state"`
+	Timer   float64 `json:"timer"`
+	Surface string  `json:"surface,omitempty"`
+
+	def    trapDef
+	pathT  float64
+	pathV  float64
+	dx, dy float64
+}
+
+type Surface struct {
+	Kind string  `json:"kind"`
+	X1   float64 `json:"x1"`
+	X2   float64 `json:"x2"`
+}
+
+type trapDef struct {
+	Line    int
+	Kind    string
+	X, Y    float64
+	X2, Y2  float64
+	Speed   float64
+	Width   float64
+	Surface string
+	On, Off float64
+}
+
+type surfaceZoneDef struct {
+	Line   int
+	Kind   string
+	X1, X2 float64
+}
+
+func (g *GameState) placeTraps(defs []trapDef, zones []surfaceZoneDef) {
+	g.Traps = g.Traps[:0]
+	for _, d := range defs {
+		t := &Trap{Kind: d.Kind, Surface: d.Surface, def: d}
+		switch d.Kind {
+		case "platform":
+			t.W, t.H = max(d.Width, platformWidth), platformHeight
+		case "falling":
+			t.W, t.H = fallingWidth, fallingHeight
+			t.State = "idle"
+		case "fire":
+			t.W, t.H = fireWidth, fireHeight
+			t.State = "off"
+		case "saw":
+			t.W, t.H = sawSize, sawSize
+		case "trampoline":
+			t.W, t.H = trampolineSize, trampolineSize
+			t.State = "idle"
+		case "spikes":
+			t.W, t.H = max(d.Width, 16), spikesHeight
+		}
+		t.X, t.Y = d.X, d.Y
+		if d.Kind == "fire" || d.Kind == "trampoline" || d.Kind == "spikes" {
+			t.Y = float64(groundY) - t.H
+		}
+		t.pathV = 1
+		g.Traps = append(g.Traps, t)
+	}
+	g.Surfaces = g.Surfaces[:0]
+	for _, z := range zones {
+		g.Surfaces = append(g.Surfaces, Surface{Kind: z.Kind, X1: z.X1, X2: z.X2})
+	}
+}
+
+// trapBox is a trap's hitbox. Traps are anchored at their top centre, except
+// saws which are anchored at their hub.
+func trapBox(t *Trap) aabb {
+	if t.Kind == "saw" {
+		return aabb{X: t.X - t.W/2, Y: t.Y - t.H/2, W: t.W, H: t.H}
+	}
+	return aabb{X: t.X - t.W/2, Y: t.Y, W: t.W, H: t.H}
+}
+
+func (t *Trap) solid() bool {
+	switch t.Kind {
+	case "platform", "trampoline":
+		return true
+	case "falling":
+		return t.State != "falling" && t.State != "gone"
+	}
+	return false
+}
+
+// followPath moves a trap back and forth between its two path points at its
+// speed and records how far it went so riders can be carried along.
+func (t *Trap) followPath(dt float64) {
+	d := t.def
+	length := math.Hypot(d.X2-d.X, d.Y2-d.Y)
+	if length == 0 || d.Speed == 0 {
+		return
+	}
+	t.pathT += t.pathV * d.Speed * dt / length
+	if t.pathT >= 1 {
+		t.pathT, t.pathV = 1, -1
+	} else if t.pathT <= 0 {
+		t.pathT, t.pathV = 0, 1
+	}
+	x := d.X + (d.X2-d.X)*t.pathT
+	y := d.Y + (d.Y2-d.Y)*t.pathT
+	t.dx, t.dy = x-t.X, y-t.Y
+	t.X, t.Y = x, y
+}
+
+func (g *GameState) updateTraps(dt float64) {
+	for _, t := range g.Traps {
+		t.dx, t.dy = 0, 0
+		t.Timer += dt
+		switch t.Kind {
+		case "platform", "saw":
+			t.followPath(dt)
+		case "fire":
+			cycle := math.Mod(t.Timer, t.def.Off+t.def.On)
+			switch {
+			case cycle < t.def.Off-fireWarnTime:
+				t.State = "off"
+			case cycle < t.def.Off:
+				t.State = "warn"
+			default:
+				t.State = "on"
+			}
+		case "falling":
+			switch t.State {
+			case "shaking":
+				if t.Timer >= fallingShakeTime {
+					t.State, t.Timer = "falling", 0
+				}
+			case "falling":
+				t.Y += 300 * dt
+				if t.Y > screenHeight {
+					t.State, t.Timer = "gone", 0
+				}
+			case "gone":
+				if t.Timer >= fallingRespawn {
+					t.State, t.Timer = "idle", 0
+					t.Y = t.def.Y
+				}
+			}
+		case "trampoline":
+			if t.State == "jump" && t.Timer >= trampolineAnim {
+				t.State = "idle"
+			}
+		}
+	}
+}
+
+// surfaceUnder returns the surface a grounded player stands on: the one of
+// the platform under them, or the ground zone at their position.
+func (g *GameState) surfaceUnder(p *Player) surfaceDef {
+	if !p.grounded {
+		return surfaceDef{Grip: 0.5, MaxSpeed: 1}
+	}
+	if p.standing != nil {
+		return surfaceDefs[p.standing.Surface]
+	}
+	for _, z := range g.Surfaces {
+		if p.X >= z.X1 && p.X <= z.X2 {
+			return surfaceDefs[z.Kind]
+		}
+	}
+	return surfaceDefs[""]
+}
+
+// landOnTraps stops a falling player on the first solid trap whose top they
+// crossed this tick. It reports whether they landed.
+func (g *GameState) landOnTraps(p *Player, prevY float64) bool {
+	for _, t := range g.Traps {
+		if !t.solid() || p.Vy < 0 {
+			continue
+		}
+		box := trapBox(t)
+		if p.X+float64(playerWidth)/2 <= box.X || p.X-float64(playerWidth)/2 >= box.X+box.W {
+			continue
+		}
+		top := box.Y
+		if prevY > top+1 || p.Y < top {
+			continue
+		}
+		p.Y = top
+		p.standing = t
+		switch t.Kind {
+		case "trampoline":
+			p.Vy = trampolineImpulse
+			p.standing = nil
+			t.State, t.Timer = "jump", 0
+			return false
+		case "falling":
+			if t.State == "idle" {
+				t.State, t.Timer = "shaking", 0
+			}
+		}
+		p.Vy = 0
+		return true
+	}
+	return false
+}
+
+// trapHazards hurts players touching lit fire jets, saws or spikes.
+func (g *GameState) trapHazards() {
+	for _, t := range g.Traps {
+		switch t.Kind {
+		case "fire":
+			if t.State != "on" {
+				continue
+			}
+		case "saw", "spikes":
+		default:
+			continue
+		}
+		box := trapBox(t)
+		g.playerGrid.query(box, func(p *Player, pBox aabb) {
+			if pBox.overlaps(box) {
+				g.damagePlayer(p)
+			}
+		})
+	}
+}
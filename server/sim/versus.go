@@ -0,0 +1,266 @@
+package sim
+
+import "sort"
+
+// In versus rooms players fight each other instead of enemy waves. They are
+// split into two teams, bullets hit the other team, and a round ends when
+// only one team has anyone standing. The first team to win Target rounds
+// wins the match. In free-for-all every player is a team of their own, and
+// with friendly fire on bullets hit teammates too.
+const (
+	versusTeams    = 2
+	versusLives    = 1
+	DefaultRounds  = 3
+	MaxRounds      = 9
+	roundBreakTime = 3.0
+	teamSpawnGap   = 40.0
+)
+
+// Rules are a versus room's settings: the rounds a team needs to win the
+// match (zero means DefaultRounds), whether bullets hit teammates, and
+// whether every player is a team of their own.
+type Rules struct {
+	Rounds       int  `json:"rounds,omitempty"`
+	FriendlyFire bool `json:"friendlyFire,omitempty"`
+	FreeForAll   bool `json:"freeForAll,omitempty"`
+}
+
+// Versus is the round state of a versus match. Wins holds each team's round
+// wins, RoundWinner the team that took the last round (0 for a draw) and
+// Break the time left before the next round starts. Waiting is set while
+// fewer than two teams have players. FreeForAll and FriendlyFire are the
+// room's rules, for the client to show.
+type Versus struct {
+	Target       int     `json:"target"`
+	Round        int     `json:"round"`
+	Wins         []int   `json:"wins"`
+	RoundWinner  int     `json:"roundWinner"`
+	Break        float64 `json:"break,omitempty"`
+	Waiting      bool    `json:"waiting,omitempty"`
+	Winner       int     `json:"winner,omitempty"`
+	FreeForAll   bool    `json:"freeForAll,omitempty"`
+	FriendlyFire bool    `json:"friendlyFire,omitempty"`
+}
+
+// versusMode plays a versus match: teams, rounds and no enemy waves.
+type versusMode struct{}
+
+func (versusMode) OnStart(g *GameState) {
+	g.startVersus()
+}
+
+// OnLevelStart puts everyone back at their side of the new arena. It
+// doesn't count as a new round: the first one is counted by startVersus.
+func (versusMode) OnLevelStart(g *GameState) {
+	g.placeTeams()
+}
+
+// OnLevelComplete is never called: versus levels have no waves to clear.
+func (versusMode) OnLevelComplete(g *GameState) {}
+
+func (versusMode) OnTick(g *GameState, dt float64) {
+	g.updateVersus(dt)
+}
+
+// OnPlayerJoin puts players joining a running match on the smaller team, or
+// on a new one in free-for-all, waiting for the next round. Players added
+// before the run starts, like a room's bots, get their team in startVersus.
+func (versusMode) OnPlayerJoin(g *GameState, p *Player) {
+	v := g.Versus
+	if v == nil {
+		return
+	}
+	if v.FreeForAll {
+		v.Wins = append(v.Wins, 0)
+		p.Team = len(v.Wins)
+	} else {
+		p.Team = g.smallestTeam()
+	}
+	p.Lives = 0
+	p.Out = true
+}
+
+func (versusMode) OnKill(g *GameState, killer *Player, e *Enemy) {
+	g.creditKill(killer)
+}
+
+// OnPlayerDown leaves p out until the next round; nobody is revived.
+func (versusMode) OnPlayerDown(g *GameState, p *Player) {
+	p.Out = true
+}
+
+func (versusMode) CheckWinCondition(g *GameState) bool {
+	return g.Versus.Winner > 0
+}
+
+// startVersus splits the players into teams in ID order and resets the
+// score.
+func (g *GameState) startVersus() {
+	v := &Versus{
+		Round:        1,
+		Target:       g.Rules.Rounds,
+		Wins:         make([]int, versusTeams),
+		FreeForAll:   g.Rules.FreeForAll,
+		FriendlyFire: g.Rules.FriendlyFire,
+	}
+	if v.Target <= 0 {
+		v.Target = DefaultRounds
+	}
+	g.Versus = v
+	players := g.SortedPlayers()
+	if v.FreeForAll {
+		v.Wins = make([]int, len(players))
+	}
+	for i, p := range players {
+		if v.FreeForAll {
+			p.Team = i + 1
+		} else {
+			p.Team = i%versusTeams + 1
+		}
+	}
+}
+
+// smallestTeam is the team with the fewest players.
+func (g *GameState) smallestTeam() int {
+	count := make([]int, versusTeams+1)
+	for _, p := range g.Players {
+		count[p.Team]++
+	}
+	best := 1
+	for t := 2; t <= versusTeams; t++ {
+		if count[t] < count[best] {
+			best = t
+		}
+	}
+	return best
+}
+
+// startRound begins the next round.
+func (g *GameState) startRound() {
+	v := g.Versus
+	v.Round++
+	v.RoundWinner = 0
+	v.Break = 0
+	g.placeTeams()
+}
+
+// placeTeams clears the arena and puts every player back on their feet at
+// their team's side: team 1 on the left, team 2 on the right. In
+// free-for-all players are spread evenly across the arena, facing the
+// middle.
+func (g *GameState) placeTeams() {
+	v := g.Versus
+	g.Bullets = g.Bullets[:0]
+	players := g.SortedPlayers()
+	placed := make([]int, len(v.Wins)+1)
+	for i, p := range players {
+		var x float64
+		switch {
+		case v.FreeForAll:
+			span := float64(screenWidth) - 2*g.startX()
+			x = g.startX() + span*float64(i)/float64(max(len(players)-1, 1))
+		case p.Team == 2:
+			x = float64(screenWidth) - g.startX() - float64(placed[p.Team])*teamSpawnGap
+		default:
+			x = g.startX() + float64(placed[p.Team])*teamSpawnGap
+		}
+		placed[p.Team]++
+		p.X, p.Y = x, float64(groundY)
+		p.Vx, p.Vy = 0, 0
+		p.Facing = 1
+		if x > screenWidth/2 {
+			p.Facing = -1
+		}
+		p.RespawnX = x
+		p.Lives = versusLives
+		p.Downed, p.Revive, p.Out = 0, 0, false
+		p.Invulnerable = invulnerableTime
+		p.standing = nil
+	}
+}
+
+// updateVersus ends the round once at most one team is standing, and picks
+// the winner once a team reaches the target.
+func (g *GameState) updateVersus(dt float64) {
+	v := g.Versus
+	if v.Break > 0 {
+		if v.Break -= dt; v.Break <= 0 {
+			g.startRound()
+		}
+		return
+	}
+
+	present := map[int]bool{}
+	standing := map[int]bool{}
+	for _, p := range g.Players {
+		present[p.Team] = true
+		if p.Lives > 0 {
+			standing[p.Team] = true
+		}
+	}
+	if len(present) < 2 {
+		v.Waiting = true
+		return
+	}
+	if v.Waiting {
+		// An opponent arrived: start over with everyone.
+		v.Waiting = false
+		g.startRound()
+		return
+	}
+	if len(standing) >= 2 {
+		return
+	}
+
+	winner := 0
+	for t := range standing {
+		winner = t
+	}
+	v.RoundWinner = winner
+	if winner > 0 {
+		v.Wins[winner-1]++
+		if v.Wins[winner-1] >= v.Target {
+			v.Winner = winner
+			return
+		}
+	}
+	v.Break = roundBreakTime
+}
+
+// hitPlayers lets player bullets hit players of the other team, or anyone
+// but the shooter with friendly fire on. A hit that takes an opponent's
+// last life counts as a kill for the shooter.
+func (g *GameState) hitPlayers() {
+	for _, bullet := range g.Bullets {
+		if bullet.From != "player" || bullet.spent {
+			continue
+		}
+		owner := g.bulletOwner(bullet)
+		box, dx, dy := g.bulletSweep(bullet)
+		var targets []*Player
+		g.playerGrid.query(sweptBounds(box, dx, dy), func(p *Player, pBox aabb) {
+			teammate := owner != nil && p.Team == owner.Team
+			if p.Lives <= 0 || p.ID == bullet.Owner || teammate && !g.Versus.FriendlyFire {
+				return
+			}
+			if _, hit := box.sweep(dx, dy, pBox); hit {
+				targets = append(targets, p)
+			}
+		})
+		sort.Slice(targets, func(i, j int) bool { return targets[i].ID < targets[j].ID })
+		for _, p := range targets {
+			if p.Invulnerable > 0 {
+				continue
+			}
+			if owner != nil {
+				owner.Stats.Hits++
+			}
+			g.damagePlayer(p)
+			if p.Lives <= 0 && (owner == nil || p.Team != owner.Team) {
+				g.creditKill(owner)
+			}
+			bullet.spent = true
+			break
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package sim
+
+import "testing"
+
+func TestVersusTeams(t *testing.T) {
+	tests := []struct {
+		name       string
+		freeForAll bool
+		want       map[string]int
+		teams      int
+	}{
+		{name: "two teams", want: map[string]int{"a": 1, "b": 2, "c": 1}, teams: 2},
+		{name: "free-for-all", freeForAll: true, want: map[string]int{"a": 1, "b": 2, "c": 3}, teams: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t, ModeVersus, Rules{FreeForAll: tt.freeForAll}, "a", "b", "c")
+			for id, team := range tt.want {
+				if got := g.Players[id].Team; got != team {
+					t.Errorf("player %s on team %d, want %d", id, got, team)
+				}
+			}
+			if got := len(g.Versus.Wins); got != tt.teams {
+				t.Errorf("%d teams scored, want %d", got, tt.teams)
+			}
+		})
+	}
+}
+
+// TestVersusJoinBeforeStart adds a bot the way a room does before its match
+// starts; startVersus gives it a team with everyone else.
+func TestVersusJoinBeforeStart(t *testing.T) {
+	g := NewGameState()
+	g.Mode = ModeVersus
+	g.Players["a"] = g.NewPlayer("a", "a")
+	g.AddPlayer("bot", "bot", true)
+	g.NewRun(1, Scripts(), Projectiles())
+	if got := g.Players["bot"].Team; got != 2 {
+		t.Errorf("bot on team %d, want 2", got)
+	}
+}
+
+func TestHitPlayers(t *testing.T) {
+	tests := []struct {
+		name         string
+		friendlyFire bool
+		target       string
+		hit          bool
+		kill         bool
+	}{
+		{name: "opponent", target: "b", hit: true, kill: true},
+		{name: "teammate", target: "c"},
+		{name: "teammate with friendly fire", friendlyFire: true, target: "c", hit: true},
+		{name: "shooter with friendly fire", friendlyFire: true, target: "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t, ModeVersus, Rules{FriendlyFire: tt.friendlyFire}, "a", "b", "c")
+			p := g.Players[tt.target]
+			for _, other := range g.Players {
+				other.X, other.Invulnerable = 100, 0
+			}
+			p.X = 300
+			g.Bullets = []*Bullet{{From: "player", Type: "player", Owner: "a", X: 320, Y: p.Y - 10, prevX: 280, prevY: p.Y - 10}}
+			g.rebuildBroadphase()
+			g.hitPlayers()
+			if hit := p.Lives < versusLives; hit != tt.hit {
+				t.Errorf("hit = %v, want %v", hit, tt.hit)
+			}
+			if kill := g.Players["a"].Stats.Kills > 0; kill != tt.kill {
+				t.Errorf("kill credited = %v, want %v", kill, tt.kill)
+			}
+		})
+	}
+}
@@ -0,0 +1,406 @@
+package sim
+
+import (
+	"embed"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const intermissionTime = 5.0
+
+type spawnDef struct {
+	Line     int
+	Kind     string
+	Count    intRange
+	At       floatRange
+	Interval float64
+	Height   floatRange
+}
+
+type itemDef struct {
+	Line   int
+	Kind   string
+	X      floatRange
+	Height floatRange
+}
+
+type waveDef struct {
+	Line   int
+	After  float64
+	Spawns []spawnDef
+	Items  []itemDef
+	Boxes  []boxDef
+}
+
+type LevelDef struct {
+	Number      int
+	Line        int
+	ScoreTarget int
+	Waves       []waveDef
+	Items       []itemDef
+	Boxes       []boxDef
+	Flags       []flagDef
+	Traps       []trapDef
+	Surfaces    []surfaceZoneDef
+	// Weather is the level's weather, or "" for a random one.
+	Weather string
+}
+
+// hasBoss reports whether the level ends in a boss fight, in which case the
+// score target does not end it early.
+func (l LevelDef) hasBoss() bool {
+	for _, w := range l.Waves {
+		for _, s := range w.Spawns {
+			if enemyKinds[s.Kind].Boss {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type enemyKind struct {
+	Speed      float64
+	ShootMin   float64
+	ShootRand  float64
+	Flying     bool
+	HP         int
+	Width      float64
+	Height     float64
+	Boss       bool
+	Projectile string
+	Aimed      bool
+	// Swoop makes a flying enemy drift toward the targeted player's height.
+	Swoop bool
+}
+
+var enemyKinds = map[string]enemyKind{
+	"walker": {Speed: 100, ShootMin: 1.5, ShootRand: 1.0, HP: 1, Width: 24, Height: 40, Projectile: "enemy"},
+	"runner": {Speed: 180, ShootMin: 3.0, ShootRand: 1.5, HP: 1, Width: 24, Height: 40, Projectile: "enemy"},
+	"flyer":  {Speed: 80, ShootMin: 2.0, ShootRand: 1.0, Flying: true, HP: 1, Width: 24, Height: 40, Projectile: "seed", Aimed: true},
+	"boss":   {Speed: 60, HP: 40, Width: 70, Height: 120, Boss: true},
+	// Night enemies (see nightKinds) don't shoot.
+	"ghost": {Speed: 120, HP: 2, Width: 30, Height: 40},
+	"bat":   {Speed: 130, Flying: true, HP: 1, Width: 30, Height: 24, Swoop: true},
+}
+
+//go:embed waves/*.wave
+var defaultScripts embed.FS
+
+var (
+	scripts      []LevelDef
+	scriptsMutex sync.Mutex
+
+	// embeddedScripts are the levels built into the binary, used until
+	// LoadScripts reads others and whenever those fail to parse.
+	embeddedScripts []LevelDef
+)
+
+func init() {
+	levels, errs := parseScriptDir(defaultScripts, "waves")
+	if len(errs) > 0 {
+		panic(fmt.Sprint("scripts de ondas embutidos inválidos: ", errs[0]))
+	}
+	embeddedScripts = levels
+	scripts = levels
+}
+
+func Scripts() []LevelDef {
+	scriptsMutex.Lock()
+	defer scriptsMutex.Unlock()
+	return scripts
+}
+
+func setScripts(levels []LevelDef) {
+	scriptsMutex.Lock()
+	scripts = levels
+	scriptsMutex.Unlock()
+}
+
+func LoadScripts(dir string) {
+	if _, err := os.Stat(dir); err == nil {
+		levels, errs := loadScriptDir(dir)
+		if len(errs) == 0 {
+			setScripts(levels)
+			return
+		}
+		for _, err := range errs {
+			log.Println("Erro no script de ondas:", err)
+		}
+		log.Println("Usando scripts de ondas embutidos")
+	}
+	setScripts(embeddedScripts)
+}
+
+// WatchScripts polls dir and swaps in the new scripts whenever a .wave file
+// changes. Running games keep the scripts they started with; the next reset
+// picks up the reloaded ones.
+func WatchScripts(dir string) {
+	last := scriptsSignature(dir)
+	for range time.Tick(time.Second) {
+		sig := scriptsSignature(dir)
+		if sig == last {
+			continue
+		}
+		last = sig
+		levels, errs := loadScriptDir(dir)
+		if len(errs) > 0 {
+			for _, err := range errs {
+				log.Println("Erro no script de ondas:", err)
+			}
+			continue
+		}
+		setScripts(levels)
+		log.Println("Scripts de ondas recarregados:", len(levels), "níveis")
+	}
+}
+
+func scriptsSignature(dir string) string {
+	names, _ := filepath.Glob(filepath.Join(dir, "*.wave"))
+	var sb strings.Builder
+	for _, name := range names {
+		info, err := os.Stat(name)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&sb, "%s:%d:%d;", name, info.Size(), info.ModTime().UnixNano())
+	}
+	return sb.String()
+}
+
+func CheckScripts(dir string) int {
+	levels, errs := loadScriptDir(dir)
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if len(errs) > 0 {
+		return 1
+	}
+	fmt.Printf("%s: %d níveis OK\n", dir, len(levels))
+	return 0
+}
+
+type difficulty struct {
+	Speed    float64
+	FireRate float64
+	BossHP   float64
+}
+
+func difficultyFor(level int) difficulty {
+	l := float64(level - 1)
+	return difficulty{
+		Speed:    1 + 0.1*l,
+		FireRate: 1 + 0.15*l,
+		BossHP:   1 + 0.25*l,
+	}
+}
+
+type activeSpawn struct {
+	def     spawnDef
+	at      float64
+	count   int
+	spawned int
+}
+
+type waveState struct {
+	time        float64
+	idle        float64
+	spawns      []activeSpawn
+	levelPoints int
+}
+
+// levelDefFor returns the script of level, or the last one past the end. A
+// run started without scripts gets empty levels.
+func (g *GameState) levelDefFor(level int) LevelDef {
+	if len(g.levels) == 0 {
+		return LevelDef{}
+	}
+	i := max(min(level, len(g.levels)), 1) - 1
+	return g.levels[i]
+}
+
+// NewRun starts a fresh game from level 1 with the given seed, level
+// scripts and projectile table; a nil table means the loaded one (see
+// Projectiles). Running games keep the scripts and table they started with.
+func (g *GameState) NewRun(seed uint64, levels []LevelDef, projectiles map[string]ProjectileDef) {
+	if projectiles == nil {
+		projectiles = Projectiles()
+	}
+	g.Seed = seed
+	g.rng = rand.New(rand.NewPCG(seed, seed))
+	g.levels = levels
+	g.projectiles = projectiles
+	g.Points = 0
+	g.GameOver = false
+	g.Enemies = []*Enemy{}
+	g.Bullets = []*Bullet{}
+	g.Items = []*Item{}
+	g.Boxes = []*Box{}
+	g.Traps = []*Trap{}
+	g.Surfaces = []Surface{}
+	g.time = 0
+	for _, p := range g.Players {
+		g.resetPlayer(p)
+	}
+	g.Versus = nil
+	g.Clock = 0
+	g.currentMode().OnStart(g)
+	g.StartLevel(1)
+}
+
+func (g *GameState) StartLevel(level int) {
+	g.Level = level
+	g.Wave = 0
+	g.Intermission = 0
+	g.BossBonus = 0
+	g.waves = waveState{levelPoints: g.Points}
+	def := g.levelDefFor(level)
+	g.startWeather(def.Weather)
+	g.placeFlags(def.Flags)
+	g.placeTraps(def.Traps, def.Surfaces)
+	g.Boxes = g.Boxes[:0]
+	g.placeBoxes(def.Boxes)
+	g.placeItems(def.Items)
+	for _, p := range g.Players {
+		p.X = g.startX()
+		p.RespawnX = p.X
+		p.standing = nil
+	}
+	g.currentMode().OnLevelStart(g)
+}
+
+func (g *GameState) startWave(wave int) {
+	g.Wave = wave + 1
+	ws := &g.waves
+	ws.time = 0
+	ws.idle = 0
+	ws.spawns = ws.spawns[:0]
+	def := g.levelDefFor(g.Level).Waves[wave]
+	g.placeItems(def.Items)
+	g.placeBoxes(def.Boxes)
+	for _, s := range def.Spawns {
+		ws.spawns = append(ws.spawns, activeSpawn{
+			def:   s,
+			at:    s.At.roll(g.rng),
+			count: s.Count.roll(g.rng),
+		})
+	}
+}
+
+func (g *GameState) spawnEnemy(kindName string, height float64) *Enemy {
+	kindName = g.nightKind(kindName)
+	kind := enemyKinds[kindName]
+	diff := difficultyFor(g.Level)
+	hp := kind.HP
+	if kind.Boss {
+		hp = int(float64(hp) * diff.BossHP)
+	}
+	enemy := Enemy{
+		Kind:       kindName,
+		X:          float64(screenWidth) + 50,
+		Y:          float64(groundY) - 10 - height,
+		Vx:         -kind.Speed * diff.Speed,
+		Vy:         0,
+		ShootTimer: (2.0 + g.rng.Float64()*1.0) / diff.FireRate,
+		Dead:       false,
+		DeathTimer: 0,
+		WalkPhase:  0,
+		HP:         hp,
+		MaxHP:      hp,
+	}
+	g.Enemies = append(g.Enemies, &enemy)
+	return &enemy
+}
+
+func (g *GameState) liveEnemies() int {
+	n := 0
+	for _, e := range g.Enemies {
+		if !e.Dead {
+			n++
+		}
+	}
+	return n
+}
+
+func (g *GameState) updateWaves(dt float64) {
+	if g.Intermission > 0 {
+		g.Intermission -= dt
+		if g.Intermission <= 0 {
+			g.StartLevel(g.Level + 1)
+		}
+		return
+	}
+
+	if g.BossBonus > 0 {
+		g.finishLevel()
+		return
+	}
+
+	def := g.levelDefFor(g.Level)
+	if def.ScoreTarget > 0 && !def.hasBoss() && g.Points-g.waves.levelPoints >= def.ScoreTarget {
+		g.finishLevel()
+		return
+	}
+
+	ws := &g.waves
+	ws.time += dt
+	pending := false
+	for i := range ws.spawns {
+		s := &ws.spawns[i]
+		for s.spawned < s.count && ws.time >= s.at+float64(s.spawned)*s.def.Interval {
+			g.spawnEnemy(s.def.Kind, s.def.Height.roll(g.rng))
+			s.spawned++
+		}
+		if s.spawned < s.count {
+			pending = true
+		}
+	}
+	if pending {
+		return
+	}
+	ws.idle += dt
+
+	if g.Wave < len(def.Waves) {
+		next := def.Waves[g.Wave]
+		if (next.After > 0 && ws.idle >= next.After) || (next.After == 0 && g.liveEnemies() == 0) {
+			g.startWave(g.Wave)
+		}
+	} else if g.liveEnemies() == 0 {
+		g.finishLevel()
+	}
+}
+
+// finishLevel is called once a level's goal is met. Levels with an end flag
+// open it and wait for a player to reach it; the others complete right away.
+func (g *GameState) finishLevel() {
+	if f := g.endFlag(); f != nil {
+		f.Open = true
+		return
+	}
+	g.completeLevel()
+}
+
+func (g *GameState) completeLevel() {
+	g.Intermission = intermissionTime
+	g.currentMode().OnLevelComplete(g)
+	remaining := g.Enemies[:0]
+	for _, e := range g.Enemies {
+		if e.Dead {
+			remaining = append(remaining, e)
+		}
+	}
+	g.Enemies = remaining
+	bullets := g.Bullets[:0]
+	for _, b := range g.Bullets {
+		if b.From != "enemy" {
+			bullets = append(bullets, b)
+		}
+	}
+	g.Bullets = bullets
+}
@@ -0,0 +1,616 @@
+package sim
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Wave scripts are plain text files, one directive per line:
+//
+//	# comentário
+//	level 1
+//	score 800
+//	wave after all dead
+//	spawn 3 walker every 3s
+//	spawn 1-2 runner at 3s
+//	wave after 10s
+//	spawn 2 flyer at 0-1s every 2.5s height 80-140
+//	item cherries x 600 height 40-80
+//	box box2 x 300 drop kiwi
+//	start x 60
+//	checkpoint x 400
+//	end x 740
+//	platform x 200 height 120 x2 400 speed 60 surface ice
+//	falling x 300 height 150
+//	fire x 500 on 1s off 2s
+//	saw x 300 height 60 x2 600 speed 120
+//	trampoline x 650
+//	spikes x 420 width 48
+//	surface mud from 100 to 300
+//	weather rain
+//
+// A bare "wave" or "after all dead" starts the wave once the previous one
+// has spawned everything and no enemy is left alive; "after <dur>" starts it
+// that long after the previous wave finished spawning. Items placed before
+// the first wave appear when the level starts, the others when their wave
+// starts; boxes work the same way. Flags (start, checkpoint, end) belong to
+// the level. Ranges like "1-2" are rolled from the room seed every time the
+// wave starts.
+//
+// Traps and surfaces also belong to the level. Heights are measured up from
+// the ground; a platform or saw given "x2"/"height2" moves back and forth
+// between the two points at its speed. Fire, trampolines and spikes always sit
+// on the ground.
+//
+// A level's weather is one of clear, rain, wind, snow or fog; levels without
+// a weather line get a random one, rolled from the room seed.
+
+type scriptError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e scriptError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+type floatRange struct {
+	Min, Max float64
+}
+
+func (r floatRange) roll(rng *rand.Rand) float64 {
+	if r.Max <= r.Min {
+		return r.Min
+	}
+	return r.Min + rng.Float64()*(r.Max-r.Min)
+}
+
+type intRange struct {
+	Min, Max int
+}
+
+func (r intRange) roll(rng *rand.Rand) int {
+	if r.Max <= r.Min {
+		return r.Min
+	}
+	return r.Min + rng.IntN(r.Max-r.Min+1)
+}
+
+func parseScriptDir(fsys fs.FS, dir string) ([]LevelDef, []error) {
+	names, err := fs.Glob(fsys, path.Join(dir, "*.wave"))
+	if err != nil {
+		return nil, []error{err}
+	}
+	if len(names) == 0 {
+		return nil, []error{fmt.Errorf("%s: nenhum arquivo .wave encontrado", dir)}
+	}
+
+	var levels []LevelDef
+	var errs []error
+	seen := map[int]string{}
+	for _, name := range names {
+		f, err := fsys.Open(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		lv, perrs := parseScript(name, f)
+		f.Close()
+		errs = append(errs, perrs...)
+		for _, l := range lv {
+			if prev, ok := seen[l.Number]; ok {
+				errs = append(errs, scriptError{name, l.Line, fmt.Sprintf("nível %d já definido em %s", l.Number, prev)})
+				continue
+			}
+			seen[l.Number] = fmt.Sprintf("%s:%d", name, l.Line)
+			levels = append(levels, l)
+		}
+	}
+
+	sort.Slice(levels, func(i, j int) bool { return levels[i].Number < levels[j].Number })
+	for i, l := range levels {
+		if l.Number != i+1 {
+			errs = append(errs, fmt.Errorf("níveis devem ser numerados a partir de 1 sem lacunas: falta o nível %d", i+1))
+			break
+		}
+	}
+	return levels, errs
+}
+
+func loadScriptDir(dir string) ([]LevelDef, []error) {
+	levels, errs := parseScriptDir(os.DirFS(dir), ".")
+	for i, err := range errs {
+		if se, ok := err.(scriptError); ok {
+			se.File = filepath.Join(dir, se.File)
+			errs[i] = se
+		}
+	}
+	return levels, errs
+}
+
+func parseScript(name string, r io.Reader) ([]LevelDef, []error) {
+	var levels []LevelDef
+	var errs []error
+	fail := func(line int, format string, args ...any) {
+		errs = append(errs, scriptError{name, line, fmt.Sprintf(format, args...)})
+	}
+
+	var level *LevelDef
+	var wave *waveDef
+	closeLevel := func() {
+		if level == nil {
+			return
+		}
+		if len(level.Waves) == 0 {
+			fail(level.Line, "nível %d não tem ondas", level.Number)
+		}
+		for _, w := range level.Waves {
+			if len(w.Spawns) == 0 {
+				fail(w.Line, "onda sem spawns")
+			}
+		}
+		levels = append(levels, *level)
+		level, wave = nil, nil
+	}
+
+	sc := bufio.NewScanner(r)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "level":
+			closeLevel()
+			if len(fields) != 2 {
+				fail(lineNo, "uso: level <número>")
+				continue
+			}
+			n, err := strconv.Atoi(fields[1])
+			if err != nil || n < 1 {
+				fail(lineNo, "número de nível inválido %q", fields[1])
+				continue
+			}
+			level = &LevelDef{Number: n, Line: lineNo}
+		case "score":
+			if level == nil {
+				fail(lineNo, "score fora de um nível")
+				continue
+			}
+			if len(fields) != 2 {
+				fail(lineNo, "uso: score <pontos>")
+				continue
+			}
+			n, err := strconv.Atoi(fields[1])
+			if err != nil || n < 0 {
+				fail(lineNo, "pontuação inválida %q", fields[1])
+				continue
+			}
+			level.ScoreTarget = n
+		case "weather":
+			if level == nil {
+				fail(lineNo, "weather fora de um nível")
+				continue
+			}
+			if len(fields) != 2 {
+				fail(lineNo, "uso: weather clear|rain|wind|snow|fog")
+				continue
+			}
+			if !validWeather(fields[1]) {
+				fail(lineNo, "clima desconhecido %q", fields[1])
+				continue
+			}
+			level.Weather = fields[1]
+		case "wave":
+			if level == nil {
+				fail(lineNo, "wave fora de um nível")
+				continue
+			}
+			w := waveDef{Line: lineNo}
+			switch {
+			case len(fields) == 1:
+			case len(fields) == 4 && fields[1] == "after" && fields[2] == "all" && fields[3] == "dead":
+			case len(fields) == 3 && fields[1] == "after":
+				d, err := parseDuration(fields[2])
+				if err != nil {
+					fail(lineNo, "%v", err)
+					continue
+				}
+				w.After = d
+			default:
+				fail(lineNo, "condição de onda inválida %q (use \"after all dead\" ou \"after <duração>\")", strings.Join(fields[1:], " "))
+				continue
+			}
+			level.Waves = append(level.Waves, w)
+			wave = &level.Waves[len(level.Waves)-1]
+		case "spawn":
+			if wave == nil {
+				fail(lineNo, "spawn fora de uma onda")
+				continue
+			}
+			s, err := parseSpawn(fields[1:])
+			if err != nil {
+				fail(lineNo, "%v", err)
+				continue
+			}
+			s.Line = lineNo
+			wave.Spawns = append(wave.Spawns, s)
+		case "item":
+			if level == nil {
+				fail(lineNo, "item fora de um nível")
+				continue
+			}
+			it, err := parseItem(fields[1:])
+			if err != nil {
+				fail(lineNo, "%v", err)
+				continue
+			}
+			it.Line = lineNo
+			if wave != nil {
+				wave.Items = append(wave.Items, it)
+			} else {
+				level.Items = append(level.Items, it)
+			}
+		case "box":
+			if level == nil {
+				fail(lineNo, "box fora de um nível")
+				continue
+			}
+			b, err := parseBox(fields[1:])
+			if err != nil {
+				fail(lineNo, "%v", err)
+				continue
+			}
+			b.Line = lineNo
+			if wave != nil {
+				wave.Boxes = append(wave.Boxes, b)
+			} else {
+				level.Boxes = append(level.Boxes, b)
+			}
+		case "start", "checkpoint", "end":
+			if level == nil {
+				fail(lineNo, "%s fora de um nível", fields[0])
+				continue
+			}
+			if len(fields) != 3 || fields[1] != "x" {
+				fail(lineNo, "uso: %s x <px>", fields[0])
+				continue
+			}
+			x, err := strconv.ParseFloat(fields[2], 64)
+			if err != nil || x < 0 || x > screenWidth {
+				fail(lineNo, "posição inválida %q", fields[2])
+				continue
+			}
+			if fields[0] != "checkpoint" && slices.ContainsFunc(level.Flags, func(f flagDef) bool { return f.Kind == fields[0] }) {
+				fail(lineNo, "%s já definido neste nível", fields[0])
+				continue
+			}
+			level.Flags = append(level.Flags, flagDef{Line: lineNo, Kind: fields[0], X: x})
+		case "platform", "falling", "fire", "saw", "trampoline", "spikes":
+			if level == nil {
+				fail(lineNo, "%s fora de um nível", fields[0])
+				continue
+			}
+			t, err := parseTrap(fields[0], fields[1:])
+			if err != nil {
+				fail(lineNo, "%v", err)
+				continue
+			}
+			t.Line = lineNo
+			level.Traps = append(level.Traps, t)
+		case "surface":
+			if level == nil {
+				fail(lineNo, "surface fora de um nível")
+				continue
+			}
+			z, err := parseSurface(fields[1:])
+			if err != nil {
+				fail(lineNo, "%v", err)
+				continue
+			}
+			z.Line = lineNo
+			level.Surfaces = append(level.Surfaces, z)
+		default:
+			fail(lineNo, "diretiva desconhecida %q", fields[0])
+		}
+	}
+	if err := sc.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	closeLevel()
+	// Errors without a line, such as read failures, come first.
+	sort.SliceStable(errs, func(i, j int) bool {
+		a, aok := errs[i].(scriptError)
+		b, bok := errs[j].(scriptError)
+		if aok != bok {
+			return bok
+		}
+		return aok && a.Line < b.Line
+	})
+	return levels, errs
+}
+
+func parseSpawn(args []string) (spawnDef, error) {
+	var s spawnDef
+	if len(args) < 2 {
+		return s, fmt.Errorf("uso: spawn <quantidade> <tipo> [at <duração>] [every <duração>] [height <px>]")
+	}
+	count, err := parseIntRange(args[0])
+	if err != nil || count.Min < 1 {
+		return s, fmt.Errorf("quantidade inválida %q", args[0])
+	}
+	s.Count = count
+	s.Kind = args[1]
+	if _, ok := enemyKinds[s.Kind]; !ok {
+		return s, fmt.Errorf("tipo de inimigo desconhecido %q", s.Kind)
+	}
+
+	opts := args[2:]
+	for len(opts) > 0 {
+		if len(opts) < 2 {
+			return s, fmt.Errorf("valor ausente para %q", opts[0])
+		}
+		key, val := opts[0], opts[1]
+		opts = opts[2:]
+		switch key {
+		case "at":
+			r, err := parseFloatRange(strings.TrimSuffix(val, "s"))
+			if err != nil {
+				return s, fmt.Errorf("duração inválida %q", val)
+			}
+			s.At = r
+		case "every":
+			d, err := parseDuration(val)
+			if err != nil {
+				return s, err
+			}
+			s.Interval = d
+		case "height":
+			r, err := parseFloatRange(val)
+			if err != nil {
+				return s, fmt.Errorf("altura inválida %q", val)
+			}
+			s.Height = r
+		default:
+			return s, fmt.Errorf("opção desconhecida %q", key)
+		}
+	}
+	if s.Count.Max > 1 && s.Interval == 0 {
+		s.Interval = 1
+	}
+	return s, nil
+}
+
+func parseItem(args []string) (itemDef, error) {
+	var it itemDef
+	if len(args) < 1 {
+		return it, fmt.Errorf("uso: item <fruta> x <px> [height <px>]")
+	}
+	it.Kind = args[0]
+	if _, ok := fruitDefs[it.Kind]; !ok {
+		return it, fmt.Errorf("fruta desconhecida %q", it.Kind)
+	}
+	hasX := false
+	opts := args[1:]
+	for len(opts) > 0 {
+		if len(opts) < 2 {
+			return it, fmt.Errorf("valor ausente para %q", opts[0])
+		}
+		key, val := opts[0], opts[1]
+		opts = opts[2:]
+		r, err := parseFloatRange(val)
+		if err != nil {
+			return it, fmt.Errorf("posição inválida %q", val)
+		}
+		switch key {
+		case "x":
+			it.X = r
+			hasX = true
+		case "height":
+			it.Height = r
+		default:
+			return it, fmt.Errorf("opção desconhecida %q", key)
+		}
+	}
+	if !hasX {
+		return it, fmt.Errorf("item sem posição x")
+	}
+	if it.X.Max > screenWidth {
+		return it, fmt.Errorf("posição fora da tela")
+	}
+	return it, nil
+}
+
+func parseBox(args []string) (boxDef, error) {
+	var b boxDef
+	if len(args) < 1 {
+		return b, fmt.Errorf("uso: box <box1|box2|box3> x <px> [height <px>] [drop <fruta>]")
+	}
+	b.Kind = args[0]
+	if _, ok := boxHP[b.Kind]; !ok {
+		return b, fmt.Errorf("caixa desconhecida %q", b.Kind)
+	}
+	hasX := false
+	opts := args[1:]
+	for len(opts) > 0 {
+		if len(opts) < 2 {
+			return b, fmt.Errorf("valor ausente para %q", opts[0])
+		}
+		key, val := opts[0], opts[1]
+		opts = opts[2:]
+		switch key {
+		case "x", "height":
+			r, err := parseFloatRange(val)
+			if err != nil {
+				return b, fmt.Errorf("posição inválida %q", val)
+			}
+			if key == "x" {
+				b.X = r
+				hasX = true
+			} else {
+				b.Height = r
+			}
+		case "drop":
+			if _, ok := fruitDefs[val]; !ok {
+				return b, fmt.Errorf("fruta desconhecida %q", val)
+			}
+			b.Drop = val
+		default:
+			return b, fmt.Errorf("opção desconhecida %q", key)
+		}
+	}
+	if !hasX {
+		return b, fmt.Errorf("caixa sem posição x")
+	}
+	if b.X.Max > screenWidth {
+		return b, fmt.Errorf("posição fora da tela")
+	}
+	return b, nil
+}
+
+func parseTrap(kind string, args []string) (trapDef, error) {
+	t := trapDef{Kind: kind, On: 1, Off: 2}
+	hasX := false
+	var height, height2 float64
+	hasX2, hasHeight2 := false, false
+	for len(args) > 0 {
+		if len(args) < 2 {
+			return t, fmt.Errorf("valor ausente para %q", args[0])
+		}
+		key, val := args[0], args[1]
+		args = args[2:]
+		if key == "surface" {
+			if _, ok := surfaceDefs[val]; !ok || val == "" {
+				return t, fmt.Errorf("superfície desconhecida %q", val)
+			}
+			t.Surface = val
+			continue
+		}
+		n, err := strconv.ParseFloat(strings.TrimSuffix(val, "s"), 64)
+		if err != nil || n < 0 {
+			return t, fmt.Errorf("valor inválido %q", val)
+		}
+		switch key {
+		case "x":
+			t.X, hasX = n, true
+		case "height":
+			height = n
+		case "x2":
+			t.X2, hasX2 = n, true
+		case "height2":
+			height2, hasHeight2 = n, true
+		case "speed":
+			t.Speed = n
+		case "width":
+			t.Width = n
+		case "on":
+			t.On = n
+		case "off":
+			t.Off = n
+		default:
+			return t, fmt.Errorf("opção desconhecida %q", key)
+		}
+	}
+	if !hasX {
+		return t, fmt.Errorf("%s sem posição x", kind)
+	}
+	if t.X > screenWidth || hasX2 && t.X2 > screenWidth {
+		return t, fmt.Errorf("posição fora da tela")
+	}
+	if !hasX2 {
+		t.X2 = t.X
+	}
+	if !hasHeight2 {
+		height2 = height
+	}
+	t.Y, t.Y2 = float64(groundY)-height, float64(groundY)-height2
+	if (hasX2 || hasHeight2) && kind != "platform" && kind != "saw" {
+		return t, fmt.Errorf("%s não se move", kind)
+	}
+	if (hasX2 || hasHeight2) && t.Speed == 0 {
+		t.Speed = 60
+	}
+	if t.Surface != "" && kind != "platform" {
+		return t, fmt.Errorf("só plataformas têm superfície")
+	}
+	if kind == "fire" && t.On+t.Off <= 0 {
+		return t, fmt.Errorf("ciclo de fogo vazio")
+	}
+	return t, nil
+}
+
+func parseSurface(args []string) (surfaceZoneDef, error) {
+	var z surfaceZoneDef
+	if len(args) != 5 || args[1] != "from" || args[3] != "to" {
+		return z, fmt.Errorf("uso: surface <ice|mud|sand> from <px> to <px>")
+	}
+	z.Kind = args[0]
+	if _, ok := surfaceDefs[z.Kind]; !ok || z.Kind == "" {
+		return z, fmt.Errorf("superfície desconhecida %q", z.Kind)
+	}
+	x1, err1 := strconv.ParseFloat(args[2], 64)
+	x2, err2 := strconv.ParseFloat(args[4], 64)
+	if err1 != nil || err2 != nil || x2 < x1 {
+		return z, fmt.Errorf("intervalo inválido %s-%s", args[2], args[4])
+	}
+	z.X1, z.X2 = x1, x2
+	return z, nil
+}
+
+func parseDuration(s string) (float64, error) {
+	d, err := strconv.ParseFloat(strings.TrimSuffix(s, "s"), 64)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("duração inválida %q", s)
+	}
+	return d, nil
+}
+
+func parseFloatRange(s string) (floatRange, error) {
+	lo, hi, isRange := strings.Cut(s, "-")
+	min, err := strconv.ParseFloat(lo, 64)
+	if err != nil || min < 0 {
+		return floatRange{}, fmt.Errorf("valor inválido %q", s)
+	}
+	if !isRange {
+		return floatRange{min, min}, nil
+	}
+	max, err := strconv.ParseFloat(hi, 64)
+	if err != nil || max < min {
+		return floatRange{}, fmt.Errorf("intervalo inválido %q", s)
+	}
+	return floatRange{min, max}, nil
+}
+
+func parseIntRange(s string) (intRange, error) {
+	lo, hi, isRange := strings.Cut(s, "-")
+	min, err := strconv.Atoi(lo)
+	if err != nil {
+		return intRange{}, err
+	}
+	if !isRange {
+		return intRange{min, min}, nil
+	}
+	max, err := strconv.Atoi(hi)
+	if err != nil || max < min {
+		return intRange{}, fmt.Errorf("intervalo inválido %q", s)
+	}
+	return intRange{min, max}, nil
+}
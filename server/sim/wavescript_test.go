@@ -0,0 +1,196 @@
+package sim
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseScriptErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "unknown directive",
+			script: "level 1\nwave\nspawn 1 walker\njump 3\n",
+			want:   []string{`t.wave:4: diretiva desconhecida "jump"`},
+		},
+		{
+			name:   "level without number",
+			script: "level\n",
+			want:   []string{"t.wave:1: uso: level <número>"},
+		},
+		{
+			name:   "bad level number",
+			script: "level 0\n",
+			want:   []string{`t.wave:1: número de nível inválido "0"`},
+		},
+		{
+			name:   "spawn outside a wave",
+			script: "level 1\nspawn 1 walker\nwave\nspawn 1 walker\n",
+			want:   []string{"t.wave:2: spawn fora de uma onda"},
+		},
+		{
+			name:   "unknown enemy kind",
+			script: "level 1\nwave\nspawn 2 dragon\n",
+			want:   []string{"t.wave:2: onda sem spawns", `t.wave:3: tipo de inimigo desconhecido "dragon"`},
+		},
+		{
+			name:   "reversed count range",
+			script: "level 1\nwave\nspawn 1 walker\nspawn 3-1 walker\n",
+			want:   []string{`t.wave:4: quantidade inválida "3-1"`},
+		},
+		{
+			name:   "reversed height range",
+			script: "level 1\nwave\nspawn 1 flyer height 140-80\n",
+			want:   []string{"t.wave:2: onda sem spawns", `t.wave:3: altura inválida "140-80"`},
+		},
+		{
+			name:   "bad wave condition",
+			script: "level 1\nwave after some dead\nwave\nspawn 1 walker\n",
+			want:   []string{`t.wave:2: condição de onda inválida "after some dead" (use "after all dead" ou "after <duração>")`},
+		},
+		{
+			name:   "reversed surface",
+			script: "level 1\nsurface mud from 300 to 100\nwave\nspawn 1 walker\n",
+			want:   []string{"t.wave:2: intervalo inválido 300-100"},
+		},
+		{
+			name:   "trap off screen",
+			script: "level 1\nsaw x 300 x2 900\nwave\nspawn 1 walker\n",
+			want:   []string{"t.wave:2: posição fora da tela"},
+		},
+		{
+			name:   "item off screen",
+			script: "level 1\nitem kiwi x 700-1200\nwave\nspawn 1 walker\n",
+			want:   []string{"t.wave:2: posição fora da tela"},
+		},
+		{
+			name:   "box off screen",
+			script: "level 1\nwave\nspawn 1 walker\nbox box1 x 900\n",
+			want:   []string{"t.wave:4: posição fora da tela"},
+		},
+		{
+			name:   "duplicate end flag",
+			script: "level 1\nend x 700\nend x 740\nwave\nspawn 1 walker\n",
+			want:   []string{"t.wave:3: end já definido neste nível"},
+		},
+		{
+			name:   "errors in line order",
+			script: "level 1\nweather hail\nlevel 2\nwave\nitem kiwi\nlevel 3\nwave\nspawn 1 walker\n",
+			want: []string{
+				"t.wave:1: nível 1 não tem ondas",
+				`t.wave:2: clima desconhecido "hail"`,
+				"t.wave:4: onda sem spawns",
+				"t.wave:5: item sem posição x",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, errs := parseScript("t.wave", strings.NewReader(tt.script))
+			var got []string
+			for _, err := range errs {
+				got = append(got, err.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestParseScript(t *testing.T) {
+	script := `# comentário
+level 2
+score 800
+weather rain
+item cherries x 600 height 40-80
+wave after 10s
+spawn 1-2 runner at 3s every 2.5s
+`
+	levels, errs := parseScript("t.wave", strings.NewReader(script))
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	want := []LevelDef{{
+		Number:      2,
+		Line:        2,
+		ScoreTarget: 800,
+		Weather:     "rain",
+		Items:       []itemDef{{Line: 5, Kind: "cherries", X: floatRange{600, 600}, Height: floatRange{40, 80}}},
+		Waves: []waveDef{{
+			Line:   6,
+			After:  10,
+			Spawns: []spawnDef{{Line: 7, Count: intRange{1, 2}, Kind: "runner", At: floatRange{3, 3}, Interval: 2.5}},
+		}},
+	}}
+	if !reflect.DeepEqual(levels, want) {
+		t.Errorf("parsed %+v, want %+v", levels, want)
+	}
+}
+
+func TestParseScriptDir(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  []string
+	}{
+		{
+			name: "duplicate level",
+			files: fstest.MapFS{
+				"a.wave": {Data: []byte("level 1\nwave\nspawn 1 walker\n")},
+				"b.wave": {Data: []byte("\nlevel 1\nwave\nspawn 1 walker\n")},
+			},
+			want: []string{"b.wave:2: nível 1 já definido em a.wave:1"},
+		},
+		{
+			name: "gap",
+			files: fstest.MapFS{
+				"a.wave": {Data: []byte("level 1\nwave\nspawn 1 walker\nlevel 3\nwave\nspawn 1 walker\n")},
+			},
+			want: []string{"níveis devem ser numerados a partir de 1 sem lacunas: falta o nível 2"},
+		},
+		{
+			name:  "no scripts",
+			files: fstest.MapFS{},
+			want:  []string{".: nenhum arquivo .wave encontrado"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, errs := parseScriptDir(tt.files, ".")
+			var got []string
+			for _, err := range errs {
+				got = append(got, err.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+// TestEmbeddedScripts checks that the scripts built into the binary parse
+// cleanly and match the ones on disk.
+func TestEmbeddedScripts(t *testing.T) {
+	embedded, errs := parseScriptDir(defaultScripts, "waves")
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	for i, l := range embedded {
+		if l.Number != i+1 {
+			t.Errorf("level %d is numbered %d", i+1, l.Number)
+		}
+	}
+	disk, errs := loadScriptDir("waves")
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if !reflect.DeepEqual(disk, embedded) {
+		t.Error("scripts in waves/ differ from the embedded ones")
+	}
+}
@@ -0,0 +1,58 @@
+package sim
+
+import "slices"
+
+// weatherKinds are the weather states, in the order a random pick is made
+// from.
+var weatherKinds = []string{"clear", "rain", "wind", "snow", "fog"}
+
+// weatherDef is how a weather state plays. Wind accelerates projectiles in
+// px/s² and pushes airborne players at windDrift times that; Grip scales
+// the grip of the ground players stand on.
+type weatherDef struct {
+	Wind float64
+	Grip float64
+}
+
+var weatherDefs = map[string]weatherDef{
+	"clear": {Grip: 1},
+	"rain":  {Grip: 0.3},
+	"wind":  {Wind: 120, Grip: 1},
+	"snow":  {Wind: 30, Grip: 0.6},
+	"fog":   {Grip: 1},
+}
+
+// windDrift is the share of the wind's strength that moves a player in the
+// air, in px/s.
+const windDrift = 0.5
+
+// Weather is the current weather as sent to clients. Wind is signed:
+// positive blows to the right.
+type Weather struct {
+	Kind string  `json:"kind"`
+	Wind float64 `json:"wind,omitempty"`
+}
+
+// startWeather sets the weather for a level: the one its script names, or
+// a random one. The wind direction is rolled too.
+func (g *GameState) startWeather(kind string) {
+	if kind == "" {
+		kind = weatherKinds[g.rng.IntN(len(weatherKinds))]
+	}
+	w := Weather{Kind: kind, Wind: weatherDefs[kind].Wind}
+	if w.Wind != 0 && g.rng.IntN(2) == 0 {
+		w.Wind = -w.Wind
+	}
+	g.Weather = w
+}
+
+func (g *GameState) currentWeather() weatherDef {
+	if def, ok := weatherDefs[g.Weather.Kind]; ok {
+		return def
+	}
+	return weatherDefs["clear"]
+}
+
+func validWeather(kind string) bool {
+	return slices.Contains(weatherKinds, kind)
+}
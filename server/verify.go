@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"go-game/server/sim"
+)
+
+// divergence is where a re-simulated run stopped matching its recording.
+// Tick is the first tick whose state hash differs; Path names the first
+// differing field in the first recorded frame from there on, such as
+// "players.ana.y" or "enemies[2].hp".
+type divergence struct {
+	Tick      int
+	FrameTick int
+	Path      string
+	Want, Got any
+}
+
+func (d *divergence) Error() string {
+	if d.Path == "" {
+		return fmt.Sprintf("divergência no tick %d (sem quadro gravado depois dele para localizar a entidade)", d.Tick)
+	}
+	return fmt.Sprintf("divergência no tick %d; no quadro do tick %d, %s: gravado %v, simulado %v", d.Tick, d.FrameTick, d.Path, d.Want, d.Got)
+}
+
+// verifyReplays re-simulates every replay in paths (files, or directories
+// holding .replay files) and reports the ones that diverge. It returns the
+// process exit code.
+func verifyReplays(paths []string) int {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	var files []string
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			names, _ := filepath.Glob(filepath.Join(p, "*"+replayExt))
+			files = append(files, names...)
+			continue
+		}
+		files = append(files, p)
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "nenhum replay para verificar")
+		return 1
+	}
+	sort.Strings(files)
+
+	failed := 0
+	for _, file := range files {
+		ticks, err := verifyReplay(file)
+		if err != nil {
+			fmt.Printf("%s: %v\n", file, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s: OK (%d ticks)\n", file, ticks)
+	}
+	if failed > 0 {
+		fmt.Printf("%d de %d replays divergiram\n", failed, len(files))
+		return 1
+	}
+	return 0
+}
+
+// verifyReplay replays the recorded inputs of one file on a fresh game and
+// compares the state hash after every tick. It returns how many ticks were
+// checked, or a *divergence.
+func verifyReplay(path string) (int, error) {
+	h, events, err := readReplay(path)
+	if err != nil {
+		return 0, err
+	}
+	if len(h.Projectiles) == 0 {
+		return 0, fmt.Errorf("replay sem a tabela de projéteis")
+	}
+	g := sim.NewGameState()
+	g.Mode, g.Rules = h.Mode, h.Rules
+	for _, hp := range h.Players {
+		p := g.NewPlayer(hp.ID, hp.Name)
+		p.Bot = hp.Bot
+		g.ApplyInput(p, sim.Input{Command: "move", Dir: hp.Dir})
+		g.Players[hp.ID] = p
+	}
+	g.NewRun(h.Seed, h.Levels, h.Projectiles)
+	if h.Level > 1 {
+		g.StartLevel(h.Level)
+	}
+
+	dt := 1 / float64(h.TickRate)
+	tick := 0
+	var div *divergence
+	for _, ev := range events {
+		for tick < ev.Tick {
+			g.Step(dt)
+			tick++
+		}
+		switch ev.Kind {
+		case "input":
+			if p, ok := g.Players[ev.Input.PlayerID]; ok {
+				g.ApplyInput(p, *ev.Input)
+			}
+		case "join":
+			g.AddPlayer(ev.ID, ev.Name, ev.Bot)
+		case "leave":
+			delete(g.Players, ev.ID)
+		case "level":
+			g.StartLevel(ev.Level)
+		case "hash", "frame":
+			if ev.Hash == 0 {
+				// The final scoreboard is recorded as a frame without a hash.
+				continue
+			}
+			snapshot, err := json.Marshal(g)
+			if err != nil {
+				return tick, err
+			}
+			if div == nil && stateHash(snapshot) != ev.Hash {
+				div = &divergence{Tick: tick}
+			}
+			if div != nil && ev.Kind == "frame" {
+				div.FrameTick = tick
+				div.Path, div.Want, div.Got = firstDifference(ev.Data, snapshot)
+				return tick, div
+			}
+		}
+	}
+	if div != nil {
+		return tick, div
+	}
+	return tick, nil
+}
+
+// firstDifference decodes two snapshots and walks them in key order,
+// returning the path of the first value that differs.
+func firstDifference(want, got []byte) (string, any, any) {
+	var a, b any
+	if json.Unmarshal(want, &a) != nil || json.Unmarshal(got, &b) != nil {
+		return "(snapshot ilegível)", nil, nil
+	}
+	return diffValues("", a, b)
+}
+
+func diffValues(path string, a, b any) (string, any, any) {
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok {
+			return path, a, b
+		}
+		keys := map[string]bool{}
+		for k := range av {
+			keys[k] = true
+		}
+		for k := range bv {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			if p, x, y := diffValues(joinPath(path, k), av[k], bv[k]); p != "" {
+				return p, x, y
+			}
+		}
+	case []any:
+		bv, ok := b.([]any)
+		if !ok {
+			return path, a, b
+		}
+		for i := range min(len(av), len(bv)) {
+			if p, x, y := diffValues(fmt.Sprintf("%s[%d]", path, i), av[i], bv[i]); p != "" {
+				return p, x, y
+			}
+		}
+		if len(av) != len(bv) {
+			return path + ".length", len(av), len(bv)
+		}
+	default:
+		if !reflect.DeepEqual(a, b) {
+			return path, a, b
+		}
+	}
+	return "", nil, nil
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}